@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+)
+
+// Portal is the login endpoint a user signs in through. Each role may only
+// sign in through its own portal, so a headquarters account cannot obtain a
+// token from the shop login and vice versa.
+type Portal string
+
+const (
+	ShopPortal   Portal = "shop"
+	MasterPortal Portal = "master"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrWrongPortal        = errors.New("account cannot sign in through this portal")
+	// ErrTwoFactorRequired means the password was right but the user has 2FA
+	// enabled and no code was given
+	ErrTwoFactorRequired    = errors.New("two-factor code required")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+// Account lookups and updates used by Login, swapped out in tests
+var (
+	getUserByUsername = db.GetUserByUsername
+	recordFailedLogin = db.RecordFailedLogin
+	resetFailedLogins = db.ResetFailedLogins
+	useTOTPStep       = db.UseTOTPStep
+	useRecoveryCode   = db.UseRecoveryCode
+)
+
+// EnrollmentTokenTTL is how long a user who must use 2FA has to enroll after
+// signing in with just a password
+const EnrollmentTokenTTL = 10 * time.Minute
+
+// LockedError is returned while an account is locked after failed logins
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.Format(time.RFC3339))
+}
+
+type LoginResult struct {
+	Token     string
+	ExpiresAt time.Time
+	User      *models.User
+	// TwoFactorEnrollmentRequired means Token is scoped to 2FA enrollment
+	TwoFactorEnrollmentRequired bool
+}
+
+// PortalForRole returns the only portal role may sign in through
+func PortalForRole(role models.UserRole) Portal {
+	if role.IsShopRole() {
+		return ShopPortal
+	}
+	return MasterPortal
+}
+
+// TokenTTL returns how long tokens issued to role stay valid. Every token
+// expires; headquarters tokens, which can settle claims, expire sooner.
+func TokenTTL(role models.UserRole) time.Duration {
+	if PortalForRole(role) == ShopPortal {
+		return config.AppConfig.ShopTokenTTL
+	}
+	return config.AppConfig.MasterTokenTTL
+}
+
+// CheckPortal returns ErrWrongPortal unless role may sign in through portal
+func CheckPortal(role models.UserRole, portal Portal) error {
+	if PortalForRole(role) != portal {
+		return ErrWrongPortal
+	}
+	return nil
+}
+
+// Login verifies the credentials and second factor, enforces lockout and the
+// role's portal, and issues a token with the role's expiry. Users who must use
+// 2FA but have not enrolled get a short-lived token that only allows
+// enrollment.
+func Login(ctx context.Context, portal Portal, req *models.ShopLoginRequest) (*LoginResult, error) {
+	user, err := getUserByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return nil, &LockedError{Until: *user.LockedUntil}
+	}
+
+	if !utils.CheckPassword(user.PasswordHash, req.Password) {
+		return nil, failedLogin(ctx, user, ErrInvalidCredentials)
+	}
+
+	// Only checked once the password is known to be right, so the response
+	// does not reveal anything about accounts to someone guessing
+	if !user.IsActive {
+		return nil, ErrAccountDisabled
+	}
+	if err := CheckPortal(user.Role, portal); err != nil {
+		slog.WarnContext(ctx, "login rejected for wrong portal", "user_id", user.ID, "role", user.Role, "portal", portal)
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		if req.OTPCode == "" && req.RecoveryCode == "" {
+			return nil, ErrTwoFactorRequired
+		}
+		ok, err := checkSecondFactor(ctx, user, req.OTPCode, req.RecoveryCode)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, failedLogin(ctx, user, ErrInvalidTwoFactorCode)
+		}
+	}
+
+	if err := resetFailedLogins(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if !user.TOTPEnabled && user.Role.RequiresTwoFactor() {
+		token, err := utils.GenerateScopedToken(user.ID, user.ShopID, user.Username, string(user.Role),
+			utils.ScopeMFAEnrollment, EnrollmentTokenTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{
+			Token:                       token,
+			ExpiresAt:                   time.Now().Add(EnrollmentTokenTTL),
+			User:                        user,
+			TwoFactorEnrollmentRequired: true,
+		}, nil
+	}
+
+	return IssueToken(user)
+}
+
+// IssueToken issues a full access token with the role's expiry
+func IssueToken(user *models.User) (*LoginResult, error) {
+	ttl := TokenTTL(user.Role)
+	token, err := utils.GenerateToken(user.ID, user.ShopID, user.Username, string(user.Role), ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl),
+		User:      user,
+	}, nil
+}
+
+// VerifyTOTP checks code against the user's authenticator secret. Each code
+// is accepted only once.
+func VerifyTOTP(ctx context.Context, user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return useTOTPStep(ctx, user.ID, step)
+}
+
+// checkSecondFactor accepts either an authenticator code or an unused
+// recovery code
+func checkSecondFactor(ctx context.Context, user *models.User, otpCode, recoveryCode string) (bool, error) {
+	if otpCode != "" {
+		return VerifyTOTP(ctx, user, otpCode)
+	}
+	return useRecoveryCode(ctx, user.ID, utils.NormalizeRecoveryCode(recoveryCode))
+}
+
+// failedLogin counts a failed attempt and returns the error for the caller:
+// a LockedError if this attempt locked the account, otherwise cause
+func failedLogin(ctx context.Context, user *models.User, cause error) error {
+	lockedUntil, err := recordFailedLogin(ctx, user.ID,
+		config.AppConfig.LoginMaxFailedAttempts, config.AppConfig.LoginLockoutDuration)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		slog.WarnContext(ctx, "account locked after failed logins", "user_id", user.ID, "locked_until", *lockedUntil)
+		return &LockedError{Until: *lockedUntil}
+	}
+	return cause
+}
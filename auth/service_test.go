@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+)
+
+const testPassword = "correct horse battery"
+
+var testPasswordHash string
+
+func TestMain(m *testing.M) {
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	if err := utils.InitJWTKeys(signer, nil); err != nil {
+		panic(err)
+	}
+	if testPasswordHash, err = utils.HashPassword(testPassword); err != nil {
+		panic(err)
+	}
+	config.AppConfig.ShopTokenTTL = 12 * time.Hour
+	config.AppConfig.MasterTokenTTL = time.Hour
+	config.AppConfig.LoginMaxFailedAttempts = 3
+	config.AppConfig.LoginLockoutDuration = 15 * time.Minute
+	m.Run()
+}
+
+// fakeAccounts stands in for the users table
+type fakeAccounts struct {
+	user           *models.User
+	failedAttempts int
+	resets         int
+	// lastStep and the recovery code hashes mirror users.totp_last_step
+	// and user_recovery_codes
+	lastStep      int64
+	recoveryCodes map[string]bool
+}
+
+func useFakeAccounts(t *testing.T, user *models.User) *fakeAccounts {
+	t.Helper()
+	accounts := &fakeAccounts{user: user, recoveryCodes: map[string]bool{}}
+
+	getUser, recordFailed, reset, useStep, useRecovery := getUserByUsername, recordFailedLogin, resetFailedLogins, useTOTPStep, useRecoveryCode
+	t.Cleanup(func() {
+		getUserByUsername, recordFailedLogin, resetFailedLogins, useTOTPStep, useRecoveryCode = getUser, recordFailed, reset, useStep, useRecovery
+	})
+
+	getUserByUsername = func(_ context.Context, username string) (*models.User, error) {
+		if accounts.user == nil || accounts.user.Username != username {
+			return nil, nil
+		}
+		return accounts.user, nil
+	}
+	recordFailedLogin = func(_ context.Context, _ string, maxAttempts int, lockout time.Duration) (*time.Time, error) {
+		accounts.failedAttempts++
+		if accounts.failedAttempts >= maxAttempts {
+			until := time.Now().Add(lockout)
+			return &until, nil
+		}
+		return nil, nil
+	}
+	resetFailedLogins = func(context.Context, string) error {
+		accounts.failedAttempts = 0
+		accounts.resets++
+		return nil
+	}
+	useTOTPStep = func(_ context.Context, _ string, step int64) (bool, error) {
+		if step <= accounts.lastStep {
+			return false, nil
+		}
+		accounts.lastStep = step
+		return true, nil
+	}
+	useRecoveryCode = func(_ context.Context, _ string, code string) (bool, error) {
+		for hash, unused := range accounts.recoveryCodes {
+			if unused && utils.CheckPassword(hash, code) {
+				accounts.recoveryCodes[hash] = false
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return accounts
+}
+
+// addRecoveryCode stores code hashed, as EnableTwoFactor does
+func (a *fakeAccounts) addRecoveryCode(t *testing.T, code string) {
+	t.Helper()
+	hash, err := utils.HashPassword(utils.NormalizeRecoveryCode(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.recoveryCodes[hash] = true
+}
+
+func testUser(role models.UserRole) *models.User {
+	return &models.User{
+		ID:           "user-1",
+		ShopID:       "shop-1",
+		Username:     "alice",
+		Role:         role,
+		IsActive:     true,
+		PasswordHash: testPasswordHash,
+	}
+}
+
+// totpAt computes the authenticator code for secret at now
+func totpAt(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(now.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestLoginByPortalRoleAndAccountState(t *testing.T) {
+	roles := []models.UserRole{
+		models.ShopStaffRole,
+		models.ShopManagerRole,
+		models.ClaimsReviewerRole,
+		models.MasterAdminRole,
+		models.AuditorRole,
+	}
+	states := []struct {
+		name  string
+		setup func(*models.User)
+	}{
+		{"active", func(*models.User) {}},
+		{"disabled", func(u *models.User) { u.IsActive = false }},
+		{"locked", func(u *models.User) {
+			until := time.Now().Add(time.Minute)
+			u.LockedUntil = &until
+		}},
+		{"2fa required", func(u *models.User) {
+			u.TOTPEnabled = true
+			u.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		}},
+	}
+
+	for _, portal := range []Portal{ShopPortal, MasterPortal} {
+		for _, role := range roles {
+			for _, state := range states {
+				t.Run(fmt.Sprintf("%s/%s/%s", portal, role, state.name), func(t *testing.T) {
+					user := testUser(role)
+					state.setup(user)
+					accounts := useFakeAccounts(t, user)
+
+					result, err := Login(context.Background(), portal, &models.ShopLoginRequest{
+						Username: "alice",
+						Password: testPassword,
+					})
+
+					rightPortal := PortalForRole(role) == portal
+					var locked *LockedError
+					switch {
+					case state.name == "locked":
+						if !errors.As(err, &locked) {
+							t.Fatalf("err = %v, want LockedError", err)
+						}
+					case state.name == "disabled":
+						if !errors.Is(err, ErrAccountDisabled) {
+							t.Fatalf("err = %v, want ErrAccountDisabled", err)
+						}
+					case !rightPortal:
+						if !errors.Is(err, ErrWrongPortal) {
+							t.Fatalf("err = %v, want ErrWrongPortal", err)
+						}
+					case state.name == "2fa required":
+						if !errors.Is(err, ErrTwoFactorRequired) {
+							t.Fatalf("err = %v, want ErrTwoFactorRequired", err)
+						}
+					default:
+						if err != nil {
+							t.Fatalf("Login: %v", err)
+						}
+						claims, err := utils.ValidateToken(result.Token)
+						if err != nil {
+							t.Fatalf("issued token does not validate: %v", err)
+						}
+						if claims.Role != string(role) {
+							t.Errorf("token role = %q, want %q", claims.Role, role)
+						}
+						// Headquarters roles must enroll in 2FA before getting a full token
+						wantEnrollment := role.RequiresTwoFactor()
+						if result.TwoFactorEnrollmentRequired != wantEnrollment {
+							t.Errorf("TwoFactorEnrollmentRequired = %v, want %v", result.TwoFactorEnrollmentRequired, wantEnrollment)
+						}
+						if wantEnrollment && claims.Scope != utils.ScopeMFAEnrollment {
+							t.Errorf("token scope = %q, want %q", claims.Scope, utils.ScopeMFAEnrollment)
+						}
+						if !wantEnrollment && claims.Scope != "" {
+							t.Errorf("token scope = %q, want full access", claims.Scope)
+						}
+						if accounts.resets != 1 {
+							t.Errorf("failed logins reset %d times, want 1", accounts.resets)
+						}
+					}
+
+					if err != nil && result != nil {
+						t.Error("result returned together with an error")
+					}
+					if accounts.failedAttempts != 0 {
+						t.Errorf("right password counted as %d failed attempts", accounts.failedAttempts)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestLoginWrongPasswordLocksAccount(t *testing.T) {
+	accounts := useFakeAccounts(t, testUser(models.ShopStaffRole))
+	req := &models.ShopLoginRequest{Username: "alice", Password: "wrong password"}
+
+	for i := 1; i < config.AppConfig.LoginMaxFailedAttempts; i++ {
+		if _, err := Login(context.Background(), ShopPortal, req); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i, err)
+		}
+	}
+	var locked *LockedError
+	if _, err := Login(context.Background(), ShopPortal, req); !errors.As(err, &locked) {
+		t.Fatalf("last attempt: err = %v, want LockedError", err)
+	}
+	if accounts.failedAttempts != config.AppConfig.LoginMaxFailedAttempts {
+		t.Errorf("failed attempts = %d, want %d", accounts.failedAttempts, config.AppConfig.LoginMaxFailedAttempts)
+	}
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	useFakeAccounts(t, nil)
+	_, err := Login(context.Background(), ShopPortal, &models.ShopLoginRequest{Username: "nobody", Password: testPassword})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLoginSecondFactor(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := testUser(models.MasterAdminRole)
+	user.TOTPEnabled = true
+	user.TOTPSecret = secret
+	accounts := useFakeAccounts(t, user)
+	accounts.addRecoveryCode(t, "abcde-12345")
+
+	login := func(otp, recovery string) (*LoginResult, error) {
+		return Login(context.Background(), MasterPortal, &models.ShopLoginRequest{
+			Username:     "alice",
+			Password:     testPassword,
+			OTPCode:      otp,
+			RecoveryCode: recovery,
+		})
+	}
+
+	code := totpAt(t, secret, time.Now())
+	result, err := login(code, "")
+	if err != nil {
+		t.Fatalf("valid code: %v", err)
+	}
+	if result.TwoFactorEnrollmentRequired {
+		t.Error("enrolled user got an enrollment token")
+	}
+
+	// The same code cannot be replayed
+	if _, err := login(code, ""); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("reused code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+	if accounts.failedAttempts != 1 {
+		t.Errorf("failed attempts = %d, want 1", accounts.failedAttempts)
+	}
+
+	if _, err := login("", "abcde-12345"); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if _, err := login("", "abcde-12345"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("reused recovery code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestVerifyTOTPRejectsReusedStep(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := testUser(models.ClaimsReviewerRole)
+	user.TOTPSecret = secret
+	useFakeAccounts(t, user)
+	ctx := context.Background()
+	now := time.Now()
+
+	previous := totpAt(t, secret, now.Add(-30*time.Second))
+	current := totpAt(t, secret, now)
+
+	if ok, err := VerifyTOTP(ctx, user, current); err != nil || !ok {
+		t.Fatalf("current code: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := VerifyTOTP(ctx, user, current); ok {
+		t.Error("code accepted twice in the same step")
+	}
+	// Still within the clock drift window, but older than the step used
+	if ok, _ := VerifyTOTP(ctx, user, previous); ok {
+		t.Error("code for an earlier step accepted after a later one was used")
+	}
+	if ok, _ := VerifyTOTP(ctx, &models.User{ID: user.ID}, current); ok {
+		t.Error("code accepted for a user without a TOTP secret")
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := testUser(models.AuditorRole)
+	user.TOTPEnabled = true
+	accounts := useFakeAccounts(t, user)
+	for _, code := range codes {
+		accounts.addRecoveryCode(t, code)
+	}
+	ctx := context.Background()
+
+	// Codes can be typed without the dash and in upper case
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if ok, err := checkSecondFactor(ctx, user, "", typed); err != nil || !ok {
+		t.Fatalf("first use: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := checkSecondFactor(ctx, user, "", codes[0]); ok {
+		t.Error("recovery code accepted twice")
+	}
+	if ok, _ := checkSecondFactor(ctx, user, "", codes[1]); !ok {
+		t.Error("other recovery code rejected after one was used")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/importer"
+	"tayaria-warranty-be/jobs"
+)
+
+// runCommand runs a one-shot command instead of the server, e.g. from cron:
+//
+//	tayaria-warranty-be send-expiry-reminders
+//	tayaria-warranty-be escalate-overdue-claims
+//	tayaria-warranty-be import-warranties [--dry-run] warranties.csv
+//
+// It closes the database before returning, so the caller can exit with a
+// failure status without skipping the cleanup.
+func runCommand(name string, args []string) error {
+	defer db.Close()
+	ctx := context.Background()
+
+	switch name {
+	case "send-expiry-reminders":
+		sent, err := jobs.SendExpiryReminders(ctx, config.AppConfig.ExpiryReminderDays)
+		if err != nil {
+			return fmt.Errorf("failed to send expiry reminders: %w", err)
+		}
+		slog.Info("expiry reminders sent", "sent", sent)
+	case "escalate-overdue-claims":
+		escalated, err := jobs.EscalateOverdueClaims(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to escalate overdue claims: %w", err)
+		}
+		slog.Info("overdue claims escalated", "escalated", escalated)
+	case "import-warranties":
+		return importWarranties(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q; expected send-expiry-reminders, escalate-overdue-claims or import-warranties", name)
+	}
+	return nil
+}
+
+// importWarranties imports a CSV file of historical warranties and prints the
+// rows that failed, so they can be fixed and the file imported again
+func importWarranties(ctx context.Context, args []string) error {
+	dryRun := false
+	if len(args) > 0 && args[0] == "--dry-run" {
+		dryRun = true
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: import-warranties [--dry-run] file.csv")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	imp, err := importer.ImportWarranties(ctx, filepath.Base(args[0]), file, dryRun, "cli")
+	if err != nil {
+		return fmt.Errorf("failed to import warranties: %w", err)
+	}
+
+	for _, rowErr := range imp.Errors {
+		fmt.Fprintf(os.Stderr, "row %d: %s %q %s\n", rowErr.Row, rowErr.Field, rowErr.Value, rowErr.Message)
+	}
+	slog.Info("warranty import finished", "import_id", imp.ID, "dry_run", imp.DryRun, "total", imp.TotalRows,
+		"imported", imp.ImportedRows, "duplicates", imp.DuplicateRows, "errors", imp.ErrorRows)
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// Version and Commit can be set at build time, e.g.
+//
+//	go build -ldflags "-X tayaria-warranty-be/config.Version=1.4.0 -X tayaria-warranty-be/config.Commit=$(git rev-parse HEAD)"
+//
+// When Commit is not set, Render's RENDER_GIT_COMMIT or the VCS revision
+// embedded by the Go toolchain is used instead.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// StartTime is when the process started
+var StartTime = time.Now()
+
+type BuildInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	StartTime time.Time `json:"start_time"`
+	Uptime    string    `json:"uptime"`
+}
+
+func GetBuildInfo() BuildInfo {
+	return BuildInfo{
+		Version:   Version,
+		Commit:    buildCommit(),
+		StartTime: StartTime,
+		Uptime:    time.Since(StartTime).Round(time.Second).String(),
+	}
+}
+
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if commit := os.Getenv("RENDER_GIT_COMMIT"); commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"crypto"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +19,87 @@ type Config struct {
 	Environment   string
 	DatabaseURL   string
 	StorageBucket string
+	Port          string
+	// StorageBackend is where uploaded files are kept; only "local" for now,
+	// under StorageLocalDir
+	StorageBackend  string
+	StorageLocalDir string
+	// MessagingBackend sends SMS/WhatsApp: "gateway" posts to
+	// MessagingGatewayURL, "fake" only logs the messages
+	MessagingBackend      string
+	MessagingGatewayURL   string
+	MessagingGatewayToken string
+	MessagingSenderID     string
+	// SMTPPassword authenticates the mailbox warranty and claim emails are
+	// sent from
+	SMTPPassword string
+	// PhoneCountryCode is added to local phone numbers, e.g. "60" for Malaysia
+	PhoneCountryCode string
+	// ClaimPhotoMaxBytes is the largest claim photo accepted per file
+	ClaimPhotoMaxBytes int64
+	// BusinessLocation is the timezone calendar dates (purchase, expiry,
+	// "today") are taken in
+	BusinessLocation *time.Location
+	// WarrantyRegistrationWindowDays is how many days after purchase a
+	// warranty can still be registered (0 disables the limit)
+	WarrantyRegistrationWindowDays int
+	// WarrantyReviewRiskScore is the duplicate risk score (0-100) from which
+	// a registration is queued for headquarters review
+	WarrantyReviewRiskScore int
+	// ExpiryReminderDays are the reminder windows, in days before a warranty
+	// expires, e.g. [30 7]
+	ExpiryReminderDays []int
+	// ExpiryReminderInterval is how often the server looks for reminders to
+	// send; 0 leaves it to the send-expiry-reminders command run from cron
+	ExpiryReminderInterval time.Duration
+	// Claim SLA targets: how long a claim may wait for the shop to accept a
+	// customer submission, for headquarters to acknowledge it, and for a
+	// decision while pending (0 sets no target)
+	ClaimSLACustomerSubmitted time.Duration
+	ClaimSLAUnacknowledged    time.Duration
+	ClaimSLAPending           time.Duration
+	// ClaimEscalationInterval is how often the server looks for overdue claims
+	// to escalate; 0 leaves it to the escalate-overdue-claims command run from
+	// cron
+	ClaimEscalationInterval time.Duration
+	// ShutdownTimeout is how long in-flight requests and background tasks get
+	// to finish after SIGTERM before the server exits anyway.
+	ShutdownTimeout time.Duration
+	// AutoMigrate applies pending db/migrations on startup
+	AutoMigrate bool
+	// ReadinessTimeout bounds each dependency check made by /readyz
+	ReadinessTimeout time.Duration
+	// ReadinessCheckMailer adds SMTP reachability to /readyz
+	ReadinessCheckMailer bool
+	// LogLevel is one of debug, info, warn, error
+	LogLevel string
+	// MetricsToken is the bearer token required by /metrics; empty disables it
+	MetricsToken string
+	// Rate limits in requests per minute (0 disables the limit)
+	LoginRateLimitPerIP       int
+	LoginRateLimitPerUsername int
+	PublicRateLimitPerIP      int
+	// TrustedProxies are the proxy CIDRs/IPs whose X-Forwarded-For is used
+	// for the client IP; TrustedPlatform is a header set by the hosting
+	// platform (e.g. CF-Connecting-IP) that takes precedence. With neither
+	// set the TCP peer address is used.
+	TrustedProxies  []string
+	TrustedPlatform string
+	// Accounts are locked for LoginLockoutDuration after
+	// LoginMaxFailedAttempts consecutive wrong passwords
+	LoginMaxFailedAttempts int
+	LoginLockoutDuration   time.Duration
+	// Token lifetimes for shop staff and headquarters roles
+	ShopTokenTTL   time.Duration
+	MasterTokenTTL time.Duration
+	// JWTSigningKey signs new tokens (Ed25519 or RSA). JWTVerificationKeys are
+	// additional public keys accepted during key rotation.
+	JWTSigningKey       crypto.Signer
+	JWTVerificationKeys []crypto.PublicKey
+	// JWTLegacySecret verifies HS256 tokens issued before JWTLegacyCutoff,
+	// when asymmetric signing was switched on
+	JWTLegacySecret []byte
+	JWTLegacyCutoff time.Time
 }
 
 var AppConfig Config
@@ -40,12 +125,10 @@ func Init() error {
 		log.Printf("Production environment detected, using system environment variables")
 	}
 
-	// Debug: Print all environment variables
-	log.Printf("Environment variables loaded:")
-	log.Printf("SUPABASE_URL: %s", os.Getenv("SUPABASE_URL"))
-	log.Printf("SUPABASE_KEY: %s", os.Getenv("SUPABASE_KEY"))
-	log.Printf("DATABASE_URL: %s", os.Getenv("DATABASE_URL"))
-	log.Printf("STORAGE_BUCKET: %s", os.Getenv("STORAGE_BUCKET"))
+	// Only report whether values are present; never log secrets themselves
+	log.Printf("Environment variables loaded: SUPABASE_URL set=%t, SUPABASE_KEY set=%t, DATABASE_URL set=%t, STORAGE_BUCKET set=%t",
+		os.Getenv("SUPABASE_URL") != "", os.Getenv("SUPABASE_KEY") != "",
+		os.Getenv("DATABASE_URL") != "", os.Getenv("STORAGE_BUCKET") != "")
 
 	AppConfig = Config{
 		SupabaseURL:   os.Getenv("SUPABASE_URL"),
@@ -53,6 +136,93 @@ func Init() error {
 		Environment:   env,
 		DatabaseURL:   os.Getenv("DATABASE_URL"),
 		StorageBucket: os.Getenv("STORAGE_BUCKET"),
+		Port:          getEnvOrDefault("PORT", "8080"),
+		LogLevel:      getEnvOrDefault("LOG_LEVEL", "info"),
+		MetricsToken:  os.Getenv("METRICS_TOKEN"),
+
+		StorageBackend:  getEnvOrDefault("STORAGE_BACKEND", "local"),
+		StorageLocalDir: getEnvOrDefault("STORAGE_LOCAL_DIR", "uploads"),
+
+		MessagingBackend:      getEnvOrDefault("MESSAGING_BACKEND", "fake"),
+		MessagingGatewayURL:   os.Getenv("MESSAGING_GATEWAY_URL"),
+		MessagingGatewayToken: os.Getenv("MESSAGING_GATEWAY_TOKEN"),
+		MessagingSenderID:     os.Getenv("MESSAGING_SENDER_ID"),
+		PhoneCountryCode:      getEnvOrDefault("PHONE_COUNTRY_CODE", "60"),
+		SMTPPassword:          os.Getenv("SMTP_PASSWORD"),
+	}
+
+	shutdownTimeout, err := getEnvDuration("SHUTDOWN_TIMEOUT", 25*time.Second)
+	if err != nil {
+		return err
+	}
+	AppConfig.ShutdownTimeout = shutdownTimeout
+
+	readinessTimeout, err := getEnvDuration("READINESS_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return err
+	}
+	AppConfig.ReadinessTimeout = readinessTimeout
+	AppConfig.AutoMigrate = getEnvBool("AUTO_MIGRATE", true)
+	AppConfig.ReadinessCheckMailer = getEnvBool("READINESS_CHECK_MAILER", false)
+
+	if AppConfig.LoginRateLimitPerIP, err = getEnvInt("LOGIN_RATE_LIMIT_PER_IP", 20); err != nil {
+		return err
+	}
+	if AppConfig.LoginRateLimitPerUsername, err = getEnvInt("LOGIN_RATE_LIMIT_PER_USERNAME", 5); err != nil {
+		return err
+	}
+	if AppConfig.PublicRateLimitPerIP, err = getEnvInt("PUBLIC_RATE_LIMIT_PER_IP", 60); err != nil {
+		return err
+	}
+	AppConfig.TrustedProxies = getEnvList("TRUSTED_PROXIES")
+	AppConfig.TrustedPlatform = os.Getenv("TRUSTED_PLATFORM")
+	if AppConfig.LoginMaxFailedAttempts, err = getEnvInt("LOGIN_MAX_FAILED_ATTEMPTS", 5); err != nil {
+		return err
+	}
+	if AppConfig.LoginLockoutDuration, err = getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute); err != nil {
+		return err
+	}
+	claimPhotoMaxBytes, err := getEnvInt("CLAIM_PHOTO_MAX_BYTES", 10<<20)
+	if err != nil {
+		return err
+	}
+	AppConfig.ClaimPhotoMaxBytes = int64(claimPhotoMaxBytes)
+	if AppConfig.BusinessLocation, err = time.LoadLocation(getEnvOrDefault("BUSINESS_TIMEZONE", "Asia/Kuala_Lumpur")); err != nil {
+		return fmt.Errorf("BUSINESS_TIMEZONE is not a valid timezone: %v", err)
+	}
+	if AppConfig.WarrantyRegistrationWindowDays, err = getEnvInt("WARRANTY_REGISTRATION_WINDOW_DAYS", 30); err != nil {
+		return err
+	}
+	if AppConfig.WarrantyReviewRiskScore, err = getEnvInt("WARRANTY_REVIEW_RISK_SCORE", 50); err != nil {
+		return err
+	}
+	if AppConfig.ExpiryReminderDays, err = getEnvIntList("EXPIRY_REMINDER_DAYS", []int{30, 7}); err != nil {
+		return err
+	}
+	if AppConfig.ExpiryReminderInterval, err = getEnvDuration("EXPIRY_REMINDER_INTERVAL", time.Hour); err != nil {
+		return err
+	}
+	if AppConfig.ClaimSLACustomerSubmitted, err = getEnvDuration("CLAIM_SLA_CUSTOMER_SUBMITTED", 48*time.Hour); err != nil {
+		return err
+	}
+	if AppConfig.ClaimSLAUnacknowledged, err = getEnvDuration("CLAIM_SLA_UNACKNOWLEDGED", 24*time.Hour); err != nil {
+		return err
+	}
+	if AppConfig.ClaimSLAPending, err = getEnvDuration("CLAIM_SLA_PENDING", 72*time.Hour); err != nil {
+		return err
+	}
+	if AppConfig.ClaimEscalationInterval, err = getEnvDuration("CLAIM_ESCALATION_INTERVAL", 15*time.Minute); err != nil {
+		return err
+	}
+	if AppConfig.ShopTokenTTL, err = getEnvDuration("SHOP_TOKEN_TTL", 30*24*time.Hour); err != nil {
+		return err
+	}
+	if AppConfig.MasterTokenTTL, err = getEnvDuration("MASTER_TOKEN_TTL", 7*24*time.Hour); err != nil {
+		return err
+	}
+
+	if err := loadJWTKeys(&AppConfig); err != nil {
+		return err
 	}
 
 	// Validate required fields
@@ -62,10 +232,97 @@ func Init() error {
 	if AppConfig.SupabaseKey == "" {
 		return fmt.Errorf("SUPABASE_KEY is not set")
 	}
+	// The fake backend only logs messages, so customers would never get them
+	if IsProduction() && AppConfig.MessagingBackend != "gateway" {
+		return fmt.Errorf("MESSAGING_BACKEND must be \"gateway\" in production, got %q", AppConfig.MessagingBackend)
+	}
+	if IsProduction() && AppConfig.MessagingGatewayURL == "" {
+		return fmt.Errorf("MESSAGING_GATEWAY_URL is not set")
+	}
 
 	return nil
 }
 
+// SupabaseHost is the host of SUPABASE_URL, where receipts are uploaded
+func SupabaseHost() string {
+	u, err := url.Parse(AppConfig.SupabaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
 func IsProduction() bool {
 	return strings.ToLower(AppConfig.Environment) == "production"
 }
+
+func getEnvOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvDuration parses a Go duration string (e.g. "30s") from the environment
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid duration: %v", key, err)
+	}
+	return d, nil
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	switch strings.ToLower(os.Getenv(key)) {
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	default:
+		return fallback
+	}
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid integer: %v", key, err)
+	}
+	return n, nil
+}
+
+// getEnvList splits a comma separated list, dropping empty items
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvIntList parses a comma separated list of integers, e.g. "30,7"
+func getEnvIntList(key string, fallback []int) ([]int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid list of integers: %v", key, err)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// loadJWTKeys reads the token signing key and any extra verification keys.
+//
+//	JWT_SIGNING_KEY       PEM private key (Ed25519 or RSA, PKCS#8 or PKCS#1)
+//	JWT_VERIFICATION_KEYS PEM public keys still accepted for verification,
+//	                      e.g. the previous signing key during rotation
+//	JWT_SECRET            legacy HS256 secret, only used to verify old tokens
+//	JWT_LEGACY_CUTOFF     RFC 3339 time asymmetric signing went live; legacy
+//	                      tokens must have been issued before it
+func loadJWTKeys(cfg *Config) error {
+	signingPEM := readPEMEnv("JWT_SIGNING_KEY")
+	if signingPEM == "" {
+		if strings.ToLower(cfg.Environment) == "production" {
+			return fmt.Errorf("JWT_SIGNING_KEY is not set")
+		}
+		// Development convenience: tokens will not survive a restart
+		log.Printf("Warning: JWT_SIGNING_KEY is not set, generating an ephemeral Ed25519 key")
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate JWT signing key: %v", err)
+		}
+		cfg.JWTSigningKey = key
+	} else {
+		key, err := parsePrivateKey([]byte(signingPEM))
+		if err != nil {
+			return fmt.Errorf("JWT_SIGNING_KEY: %v", err)
+		}
+		cfg.JWTSigningKey = key
+	}
+
+	rest := []byte(readPEMEnv("JWT_VERIFICATION_KEYS"))
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("JWT_VERIFICATION_KEYS: %v", err)
+		}
+		if !isSupportedPublicKey(key) {
+			return fmt.Errorf("JWT_VERIFICATION_KEYS: unsupported key type %T", key)
+		}
+		cfg.JWTVerificationKeys = append(cfg.JWTVerificationKeys, key)
+	}
+
+	cfg.JWTLegacySecret = []byte(os.Getenv("JWT_SECRET"))
+	if len(cfg.JWTLegacySecret) > 0 {
+		cutoff := os.Getenv("JWT_LEGACY_CUTOFF")
+		if cutoff == "" {
+			return fmt.Errorf("JWT_LEGACY_CUTOFF must be set when JWT_SECRET is")
+		}
+		var err error
+		if cfg.JWTLegacyCutoff, err = time.Parse(time.RFC3339, cutoff); err != nil {
+			return fmt.Errorf("JWT_LEGACY_CUTOFF is not an RFC 3339 time: %v", err)
+		}
+	}
+	return nil
+}
+
+// readPEMEnv reads a PEM value from the environment, accepting escaped
+// newlines since some dashboards only take single-line values
+func readPEMEnv(key string) string {
+	return strings.ReplaceAll(os.Getenv(key), `\n`, "\n")
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case ed25519.PrivateKey:
+			return k, nil
+		case *rsa.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func isSupportedPublicKey(key crypto.PublicKey) bool {
+	switch key.(type) {
+	case ed25519.PublicKey, *rsa.PublicKey:
+		return true
+	default:
+		return false
+	}
+}
@@ -2,17 +2,15 @@ package db
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"tayaria-warranty-be/models"
 
 	"github.com/jackc/pgx/v5"
 )
 
-func GetShopByUsername(username string) (*models.Shop, error) {
-	ctx := context.Background()
-
+func GetShopByUsername(ctx context.Context, username string) (*models.Shop, error) {
 	query := `
-		SELECT id, shop_name, address, contact, username, password, role, created_at, updated_at
+		SELECT id, shop_name, address, contact, username, role, created_at, updated_at
 		FROM shops
 		WHERE username = $1
 	`
@@ -30,7 +28,6 @@ func GetShopByUsername(username string) (*models.Shop, error) {
 		&shop.Address,
 		&shop.Contact,
 		&shop.Username,
-		&shop.Password,
 		&shop.Role,
 		&shop.CreatedAt,
 		&shop.UpdatedAt,
@@ -39,35 +36,35 @@ func GetShopByUsername(username string) (*models.Shop, error) {
 		if err == pgx.ErrNoRows {
 			return nil, nil // Return nil for not found instead of error
 		}
-		log.Printf("Error querying shop: %v", err)
+		slog.ErrorContext(ctx, "failed to query shop", "error", err)
 		return nil, err
 	}
 
 	return &shop, nil
 }
 
-func CreateShop(req *models.CreateRetailAccountRequest) (*models.Shop, error) {
-	ctx := context.Background()
-
+// CreateShop creates a retail shop together with its first user, a shop
+// manager using the requested credentials. passwordHash is the bcrypt hash of
+// req.Password.
+func CreateShop(ctx context.Context, req *models.CreateRetailAccountRequest, passwordHash string) (*models.Shop, error) {
 	query := `
-		INSERT INTO shops (shop_name, address, contact, username, password, role)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, shop_name, address, contact, username, password, role, created_at, updated_at
+		INSERT INTO shops (shop_name, address, contact, username, role)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, shop_name, address, contact, username, role, created_at, updated_at
 	`
 
-	conn, err := db.Acquire(ctx)
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Release()
+	defer tx.Rollback(ctx)
 
 	var shop models.Shop
-	err = conn.Conn().QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		req.ShopName,
 		req.Address,
 		req.Contact,
 		req.Username,
-		req.Password,
 		models.AdminRole, // Default role for retail accounts
 	).Scan(
 		&shop.ID,
@@ -75,24 +72,64 @@ func CreateShop(req *models.CreateRetailAccountRequest) (*models.Shop, error) {
 		&shop.Address,
 		&shop.Contact,
 		&shop.Username,
-		&shop.Password,
 		&shop.Role,
 		&shop.CreatedAt,
 		&shop.UpdatedAt,
 	)
 	if err != nil {
-		log.Printf("Error creating shop: %v", err)
+		slog.ErrorContext(ctx, "failed to create shop", "error", err)
+		return nil, err
+	}
+
+	userQuery := `
+		INSERT INTO users (shop_id, username, password_hash, name, role)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err = tx.Exec(ctx, userQuery, shop.ID, req.Username, passwordHash, req.ShopName, models.ShopManagerRole); err != nil {
+		slog.ErrorContext(ctx, "failed to create shop manager user", "error", err)
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 
 	return &shop, nil
 }
 
-func GetAllShops() ([]models.Shop, error) {
-	ctx := context.Background()
+// GetShopByID retrieves a shop by its ID, returning nil if it does not exist
+func GetShopByID(ctx context.Context, shopID string) (*models.Shop, error) {
+	query := `
+		SELECT id, shop_name, address, contact, username, role, created_at, updated_at
+		FROM shops
+		WHERE id = $1
+	`
+
+	var shop models.Shop
+	err := db.QueryRow(ctx, query, shopID).Scan(
+		&shop.ID,
+		&shop.ShopName,
+		&shop.Address,
+		&shop.Contact,
+		&shop.Username,
+		&shop.Role,
+		&shop.CreatedAt,
+		&shop.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		slog.ErrorContext(ctx, "failed to query shop", "shop_id", shopID, "error", err)
+		return nil, err
+	}
+
+	return &shop, nil
+}
 
+func GetAllShops(ctx context.Context) ([]models.Shop, error) {
 	query := `
-		SELECT id, shop_name, address, contact, username, password, role, created_at, updated_at
+		SELECT id, shop_name, address, contact, username, role, created_at, updated_at
 		FROM shops
 		WHERE role = $1
 		ORDER BY created_at DESC
@@ -106,7 +143,7 @@ func GetAllShops() ([]models.Shop, error) {
 
 	rows, err := conn.Conn().Query(ctx, query, models.AdminRole)
 	if err != nil {
-		log.Printf("Error querying shops: %v", err)
+		slog.ErrorContext(ctx, "failed to query shops", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -120,13 +157,12 @@ func GetAllShops() ([]models.Shop, error) {
 			&shop.Address,
 			&shop.Contact,
 			&shop.Username,
-			&shop.Password,
 			&shop.Role,
 			&shop.CreatedAt,
 			&shop.UpdatedAt,
 		)
 		if err != nil {
-			log.Printf("Error scanning shop: %v", err)
+			slog.ErrorContext(ctx, "failed to scan shop", "error", err)
 			continue
 		}
 		shops = append(shops, shop)
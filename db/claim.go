@@ -3,7 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"tayaria-warranty-be/models"
@@ -13,15 +13,107 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// CreateClaim creates a new claim in the database
-func CreateClaim(claim models.CreateClaimRequest, shopID string) (*models.Claim, error) {
+const claimColumns = `
+	c.id, c.warranty_id, c.shop_id, s.shop_name, s.contact, c.status, c.rejection_reason,
+	c.date_settled, c.date_closed, c.customer_name, c.phone_number, c.email, c.car_plate,
+	c.description, c.photo_urls, c.tracking_ref, c.created_at, c.updated_at, c.status_changed_at
+`
+
+const claimFrom = `
+	FROM claims c
+	LEFT JOIN shops s ON c.shop_id = s.id
+`
+
+func scanClaim(row pgx.Row) (*models.Claim, error) {
+	var claim models.Claim
+	var shopName, contact, rejectionReason, email, description, trackingRef pgtype.Text
+	var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamptz
+
+	err := row.Scan(
+		&claim.ID,
+		&claim.WarrantyID,
+		&claim.ShopID,
+		&shopName,
+		&contact,
+		&claim.Status,
+		&rejectionReason,
+		&dateSettled,
+		&dateClosed,
+		&claim.CustomerName,
+		&claim.PhoneNumber,
+		&email,
+		&claim.CarPlate,
+		&description,
+		&claim.PhotoURLs,
+		&trackingRef,
+		&createdAt,
+		&updatedAt,
+		&claim.StatusChangedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert pgtype values to Go types
+	claim.ShopName = shopName.String
+	claim.Contact = contact.String
+	claim.RejectionReason = rejectionReason.String
+	claim.Email = email.String
+	claim.Description = description.String
+	claim.TrackingRef = trackingRef.String
+	if dateSettled.Valid {
+		claim.DateSettled = &dateSettled.Time
+	}
+	if dateClosed.Valid {
+		claim.DateClosed = &dateClosed.Time
+	}
+	if createdAt.Valid {
+		claim.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Valid {
+		claim.UpdatedAt = updatedAt.Time
+	}
+	if claim.PhotoURLs == nil {
+		claim.PhotoURLs = []string{}
+	}
+	claim.CheckSLA(time.Now())
+	return &claim, nil
+}
+
+// queryClaims runs a query selecting claimColumns and scans every row
+func queryClaims(ctx context.Context, query string, args ...interface{}) ([]models.Claim, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claims: %v", err)
+	}
+	defer rows.Close()
+
+	claims := []models.Claim{} // Initialize empty slice
+	for rows.Next() {
+		claim, err := scanClaim(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan claim: %v", err)
+		}
+		claims = append(claims, *claim)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claims: %v", err)
+	}
+
+	return claims, nil
+}
+
+// CreateClaim creates a new claim in the database, together with the shop's
+// inspection of the damaged tyres if there is one
+func CreateClaim(ctx context.Context, claim models.CreateClaimRequest, shopID string, inspections []models.TyreInspection) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
 	// First verify if the shop exists
 	var exists bool
-	err := db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM shops WHERE id = $1)", shopID).Scan(&exists)
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM shops WHERE id = $1)", shopID).Scan(&exists)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check shop existence: %v", err)
 	}
@@ -36,7 +128,7 @@ func CreateClaim(claim models.CreateClaimRequest, shopID string) (*models.Claim,
 	}
 
 	// First, validate that the car plate has a valid warranty
-	warranty, err := GetValidWarrantyByCarPlate(claim.CarPlate)
+	warranty, err := GetValidWarrantyByCarPlate(ctx, claim.CarPlate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check warranty: %v", err)
 	}
@@ -50,233 +142,210 @@ func CreateClaim(claim models.CreateClaimRequest, shopID string) (*models.Claim,
 	query := `
 		INSERT INTO claims (id, warranty_id, shop_id, status, customer_name, phone_number, email, car_plate)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, warranty_id, shop_id, status, rejection_reason, date_settled, date_closed, 
-		          customer_name, phone_number, email, car_plate, created_at, updated_at
 	`
 
-	log.Printf("Creating claim with params: claimID=%s, warrantyID=%s, shopID=%s", claimID, warranty.ID, shopUUID)
+	slog.DebugContext(ctx, "creating claim", "claim_id", claimID, "warranty_id", warranty.ID, "shop_id", shopUUID.String())
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
 
-	row := db.QueryRow(context.Background(), query,
+	_, err = tx.Exec(ctx, query,
 		claimID,
 		nil,
 		shopUUID,
-		"unacknowledged", // Default status
+		models.UnacknowledgedStatus, // Default status
 		claim.CustomerName,
 		claim.PhoneNumber,
 		claim.Email,
-		claim.CarPlate,
+		models.NormalizeCarPlate(claim.CarPlate),
 	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create claim: %v", err)
+	}
 
-	var result models.Claim
-	var rejectionReason pgtype.Text
-	var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
+	if err = insertInspections(ctx, tx, claimID, inspections); err != nil {
+		return nil, err
+	}
 
-	err = row.Scan(
-		&result.ID,
-		&result.WarrantyID,
-		&result.ShopID,
-		&result.Status,
-		&rejectionReason,
-		&dateSettled,
-		&dateClosed,
-		&result.CustomerName,
-		&result.PhoneNumber,
-		&result.Email,
-		&result.CarPlate,
-		&createdAt,
-		&updatedAt,
-	)
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
 
+	return GetClaimByID(ctx, claimID)
+}
+
+// CreateCustomerClaim creates a claim started by a customer. It waits in
+// customer_submitted until the chosen shop accepts it.
+func CreateCustomerClaim(ctx context.Context, claim models.CustomerClaimRequest) (*models.Claim, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	// Customers can only claim on a valid warranty, same as shops
+	warranty, err := GetValidWarrantyByCarPlate(ctx, claim.CarPlate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create claim: %v", err)
+		return nil, fmt.Errorf("failed to check warranty: %v", err)
+	}
+	if warranty == nil {
+		return nil, fmt.Errorf("no valid warranty found for car plate %s", claim.CarPlate)
 	}
 
-	// Convert pgtype values to Go types
-	if rejectionReason.Valid {
-		result.RejectionReason = rejectionReason.String
+	if claim.PhotoURLs == nil {
+		claim.PhotoURLs = []string{}
 	}
-	if dateSettled.Valid {
-		result.DateSettled = &dateSettled.Time
+
+	claimID := uuid.New().String()
+
+	query := `
+		INSERT INTO claims (id, shop_id, status, customer_name, phone_number, email, car_plate,
+		                    description, photo_urls)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	slog.DebugContext(ctx, "creating customer claim", "claim_id", claimID, "warranty_id", warranty.ID, "shop_id", claim.ShopID)
+
+	_, err = db.Exec(ctx, query,
+		claimID,
+		claim.ShopID,
+		models.CustomerSubmittedStatus,
+		claim.CustomerName,
+		claim.PhoneNumber,
+		claim.Email,
+		models.NormalizeCarPlate(claim.CarPlate),
+		claim.Description,
+		claim.PhotoURLs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create claim: %v", err)
 	}
-	if dateClosed.Valid {
-		result.DateClosed = &dateClosed.Time
+
+	return GetClaimByID(ctx, claimID)
+}
+
+// AcceptCustomerClaim moves a customer-submitted claim of the given shop into
+// the normal unacknowledged flow. It returns nil if there is no such claim.
+func AcceptCustomerClaim(ctx context.Context, claimID, shopID string) (*models.Claim, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
 	}
-	if createdAt.Valid {
-		result.CreatedAt = createdAt.Time
+
+	query := `
+		UPDATE claims
+		SET status = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND shop_id = $2 AND status = $4
+	`
+
+	tag, err := db.Exec(ctx, query, claimID, shopID, models.UnacknowledgedStatus, models.CustomerSubmittedStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept claim: %v", err)
 	}
-	if updatedAt.Valid {
-		result.UpdatedAt = updatedAt.Time
+	if tag.RowsAffected() == 0 {
+		return nil, nil
 	}
 
-	return &result, nil
+	return GetClaimByID(ctx, claimID)
 }
 
 // GetShopClaims retrieves claims for a specific shop
-func GetShopClaims(shopID string) ([]models.Claim, error) {
+func GetShopClaims(ctx context.Context, shopID string) ([]models.Claim, error) {
 	if db == nil {
 		return []models.Claim{}, fmt.Errorf("database connection not initialized")
 	}
 
-	query := `
-		SELECT c.id, c.warranty_id, c.shop_id, s.shop_name, s.contact, c.status, c.rejection_reason, 
-		       c.date_settled, c.date_closed, c.customer_name, c.phone_number, c.email, c.car_plate, 
-		       c.created_at, c.updated_at
-		FROM claims c
-		LEFT JOIN shops s ON c.shop_id = s.id
+	query := `SELECT ` + claimColumns + claimFrom + `
 		WHERE c.shop_id = $1
 		ORDER BY c.created_at DESC
 	`
 
-	log.Printf("Executing SQL query: %s with params: [%s]", query, shopID)
+	slog.DebugContext(ctx, "querying shop claims", "shop_id", shopID)
 
-	rows, err := db.Query(context.Background(), query, shopID)
+	claims, err := queryClaims(ctx, query, shopID)
 	if err != nil {
-		return []models.Claim{}, fmt.Errorf("failed to query claims: %v", err)
+		return []models.Claim{}, err
 	}
-	defer rows.Close()
+	return claims, nil
+}
 
-	claims := []models.Claim{} // Initialize empty slice
-	for rows.Next() {
-		var claim models.Claim
-		var rejectionReason pgtype.Text
-		var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
-		var shopName, contact pgtype.Text
+// GetClaimByID retrieves a claim by its ID
+func GetClaimByID(ctx context.Context, claimID string) (*models.Claim, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
 
-		err := rows.Scan(
-			&claim.ID,
-			&claim.WarrantyID,
-			&claim.ShopID,
-			&shopName,
-			&contact,
-			&claim.Status,
-			&rejectionReason,
-			&dateSettled,
-			&dateClosed,
-			&claim.CustomerName,
-			&claim.PhoneNumber,
-			&claim.Email,
-			&claim.CarPlate,
-			&createdAt,
-			&updatedAt,
-		)
+	query := `SELECT ` + claimColumns + claimFrom + `WHERE c.id = $1`
 
-		if err != nil {
-			return []models.Claim{}, fmt.Errorf("failed to scan claim: %v", err)
-		}
+	slog.DebugContext(ctx, "querying claim", "claim_id", claimID)
 
-		// Convert pgtype values to Go types
-		if shopName.Valid {
-			claim.ShopName = shopName.String
-		}
-		if contact.Valid {
-			claim.Contact = contact.String
-		}
-		if rejectionReason.Valid {
-			claim.RejectionReason = rejectionReason.String
-		}
-		if dateSettled.Valid {
-			claim.DateSettled = &dateSettled.Time
-		}
-		if dateClosed.Valid {
-			claim.DateClosed = &dateClosed.Time
-		}
-		if createdAt.Valid {
-			claim.CreatedAt = createdAt.Time
-		}
-		if updatedAt.Valid {
-			claim.UpdatedAt = updatedAt.Time
+	claim, err := scanClaim(db.QueryRow(ctx, query, claimID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil // Claim not found
 		}
-
-		claims = append(claims, claim)
-	}
-
-	if err = rows.Err(); err != nil {
-		return []models.Claim{}, fmt.Errorf("error iterating claims: %v", err)
+		return nil, fmt.Errorf("failed to get claim: %v", err)
 	}
 
-	return claims, nil
+	return claim, nil
 }
 
-// GetClaimByID retrieves a claim by its ID
-func GetClaimByID(claimID string) (*models.Claim, error) {
+// GetClaimByTrackingRef retrieves a claim by the reference given to the
+// customer, returning nil if not found
+func GetClaimByTrackingRef(ctx context.Context, trackingRef string) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
-	query := `
-		SELECT c.id, c.warranty_id, c.shop_id, s.shop_name, s.contact, c.status, c.rejection_reason, 
-		       c.date_settled, c.date_closed, c.customer_name, c.phone_number, c.email, c.car_plate, 
-		       c.created_at, c.updated_at
-		FROM claims c
-		LEFT JOIN shops s ON c.shop_id = s.id
-		WHERE c.id = $1
-	`
-
-	log.Printf("Executing SQL query: %s with params: [%s]", query, claimID)
-
-	row := db.QueryRow(context.Background(), query, claimID)
-
-	var claim models.Claim
-	var rejectionReason pgtype.Text
-	var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
-	var shopName, contact pgtype.Text
-
-	err := row.Scan(
-		&claim.ID,
-		&claim.WarrantyID,
-		&claim.ShopID,
-		&shopName,
-		&contact,
-		&claim.Status,
-		&rejectionReason,
-		&dateSettled,
-		&dateClosed,
-		&claim.CustomerName,
-		&claim.PhoneNumber,
-		&claim.Email,
-		&claim.CarPlate,
-		&createdAt,
-		&updatedAt,
-	)
+	query := `SELECT ` + claimColumns + claimFrom + `WHERE c.tracking_ref = $1`
 
+	claim, err := scanClaim(db.QueryRow(ctx, query, trackingRef))
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, nil // Claim not found
+			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get claim: %v", err)
 	}
 
-	// Check if shop exists (should not be null for valid claims)
-	if !shopName.Valid {
-		return nil, fmt.Errorf("shop not found for claim %s", claimID)
-	}
+	return claim, nil
+}
 
-	// Convert pgtype values to Go types
-	claim.ShopName = shopName.String
-	if contact.Valid {
-		claim.Contact = contact.String
-	}
-	if rejectionReason.Valid {
-		claim.RejectionReason = rejectionReason.String
-	}
-	if dateSettled.Valid {
-		claim.DateSettled = &dateSettled.Time
+// GetClaimStatusHistory returns the status changes of a claim, oldest first
+func GetClaimStatusHistory(ctx context.Context, claimID string) ([]models.ClaimStatusEvent, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
 	}
-	if dateClosed.Valid {
-		claim.DateClosed = &dateClosed.Time
+
+	query := `
+		SELECT status, changed_at
+		FROM claim_status_history
+		WHERE claim_id = $1
+		ORDER BY changed_at ASC`
+
+	rows, err := db.Query(ctx, query, claimID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claim history: %v", err)
 	}
-	if createdAt.Valid {
-		claim.CreatedAt = createdAt.Time
+	defer rows.Close()
+
+	events := []models.ClaimStatusEvent{}
+	for rows.Next() {
+		var event models.ClaimStatusEvent
+		if err := rows.Scan(&event.Status, &event.At); err != nil {
+			return nil, fmt.Errorf("failed to scan claim history: %v", err)
+		}
+		events = append(events, event)
 	}
-	if updatedAt.Valid {
-		claim.UpdatedAt = updatedAt.Time
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claim history: %v", err)
 	}
 
-	return &claim, nil
+	return events, nil
 }
 
 // UpdateClaimStatus updates the status of a claim
-func UpdateClaimStatus(claimID string, status models.ClaimStatus, rejectionReason string) (*models.Claim, error) {
+func UpdateClaimStatus(ctx context.Context, claimID string, status models.ClaimStatus, rejectionReason string) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
@@ -285,172 +354,46 @@ func UpdateClaimStatus(claimID string, status models.ClaimStatus, rejectionReaso
 		UPDATE claims 
 		SET status = $2, rejection_reason = $3, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
-		RETURNING id, warranty_id, shop_id, status, rejection_reason, date_settled, date_closed,
-		          customer_name, phone_number, email, car_plate, created_at, updated_at
 	`
 
-	log.Printf("Executing SQL query: %s with params: [%s, %s, %s]", query, claimID, status, rejectionReason)
-
-	row := db.QueryRow(context.Background(), query, claimID, status, rejectionReason)
-
-	var claim models.Claim
-	var rejectionReasonDB pgtype.Text
-	var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
-
-	err := row.Scan(
-		&claim.ID,
-		&claim.WarrantyID,
-		&claim.ShopID,
-		&claim.Status,
-		&rejectionReasonDB,
-		&dateSettled,
-		&dateClosed,
-		&claim.CustomerName,
-		&claim.PhoneNumber,
-		&claim.Email,
-		&claim.CarPlate,
-		&createdAt,
-		&updatedAt,
-	)
+	slog.DebugContext(ctx, "updating claim status", "claim_id", claimID, "status", status)
 
+	tag, err := db.Exec(ctx, query, claimID, status, rejectionReason)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("claim not found")
-		}
 		return nil, fmt.Errorf("failed to update claim status: %v", err)
 	}
-
-	// Convert pgtype values to Go types
-	if rejectionReasonDB.Valid {
-		claim.RejectionReason = rejectionReasonDB.String
-	}
-	if dateSettled.Valid {
-		claim.DateSettled = &dateSettled.Time
-	}
-	if dateClosed.Valid {
-		claim.DateClosed = &dateClosed.Time
-	}
-	if createdAt.Valid {
-		claim.CreatedAt = createdAt.Time
-	}
-	if updatedAt.Valid {
-		claim.UpdatedAt = updatedAt.Time
-	}
-
-	// Get shop information
-	shopQuery := `SELECT shop_name, contact FROM shops WHERE id = $1`
-	var shopName, contact pgtype.Text
-	err = db.QueryRow(context.Background(), shopQuery, claim.ShopID).Scan(&shopName, &contact)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get shop information: %v", err)
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("claim not found")
 	}
 
-	if shopName.Valid {
-		claim.ShopName = shopName.String
-	}
-	if contact.Valid {
-		claim.Contact = contact.String
-	}
-
-	return &claim, nil
+	return GetClaimByID(ctx, claimID)
 }
 
 // UpdateClaimWarrantyID updates the warranty_id of a claim
-func UpdateClaimWarrantyID(claimID string, warrantyID string) (*models.Claim, error) {
+func UpdateClaimWarrantyID(ctx context.Context, claimID string, warrantyID string) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
-	tx, err := db.Begin(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %v", err)
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback(context.Background())
-		}
-	}()
-
-	// 1. Update the claim's warranty_id
-	claimUpdateQuery := `
+	query := `
 		UPDATE claims 
 		SET warranty_id = $2, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
-		RETURNING id, warranty_id, shop_id, status, rejection_reason, date_settled, date_closed,
-		          customer_name, phone_number, email, car_plate, created_at, updated_at
 	`
-	row := tx.QueryRow(context.Background(), claimUpdateQuery, claimID, warrantyID)
 
-	var claim models.Claim
-	var rejectionReason pgtype.Text
-	var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
-
-	err = row.Scan(
-		&claim.ID,
-		&claim.WarrantyID,
-		&claim.ShopID,
-		&claim.Status,
-		&rejectionReason,
-		&dateSettled,
-		&dateClosed,
-		&claim.CustomerName,
-		&claim.PhoneNumber,
-		&claim.Email,
-		&claim.CarPlate,
-		&createdAt,
-		&updatedAt,
-	)
+	tag, err := db.Exec(ctx, query, claimID, warrantyID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			tx.Rollback(context.Background())
-			return nil, fmt.Errorf("claim not found")
-		}
-		tx.Rollback(context.Background())
 		return nil, fmt.Errorf("failed to update claim warranty: %v", err)
 	}
-
-	err = tx.Commit(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %v", err)
-	}
-
-	// Convert pgtype values to Go types
-	if rejectionReason.Valid {
-		claim.RejectionReason = rejectionReason.String
-	}
-	if dateSettled.Valid {
-		claim.DateSettled = &dateSettled.Time
-	}
-	if dateClosed.Valid {
-		claim.DateClosed = &dateClosed.Time
-	}
-	if createdAt.Valid {
-		claim.CreatedAt = createdAt.Time
-	}
-	if updatedAt.Valid {
-		claim.UpdatedAt = updatedAt.Time
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("claim not found")
 	}
 
-	// Get shop information
-	shopQuery := `SELECT shop_name, contact FROM shops WHERE id = $1`
-	var shopName, contact pgtype.Text
-	err = db.QueryRow(context.Background(), shopQuery, claim.ShopID).Scan(&shopName, &contact)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get shop information: %v", err)
-	}
-
-	if shopName.Valid {
-		claim.ShopName = shopName.String
-	}
-	if contact.Valid {
-		claim.Contact = contact.String
-	}
-
-	return &claim, nil
+	return GetClaimByID(ctx, claimID)
 }
 
 // CheckWarrantyExists checks if a warranty exists
-func CheckWarrantyExists(warrantyID string) (bool, error) {
+func CheckWarrantyExists(ctx context.Context, warrantyID string) (bool, error) {
 	if db == nil {
 		return false, fmt.Errorf("database connection not initialized")
 	}
@@ -458,7 +401,7 @@ func CheckWarrantyExists(warrantyID string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM warranties WHERE id = $1)`
 
 	var exists bool
-	err := db.QueryRow(context.Background(), query, warrantyID).Scan(&exists)
+	err := db.QueryRow(ctx, query, warrantyID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check warranty existence: %v", err)
 	}
@@ -466,135 +409,52 @@ func CheckWarrantyExists(warrantyID string) (bool, error) {
 	return exists, nil
 }
 
-// GetClaimsByStatus retrieves claims based on status type
-func GetClaimsByStatus(statusType string) ([]models.Claim, error) {
-	if db == nil {
-		return nil, fmt.Errorf("database connection not initialized")
-	}
-
-	var query string
-	var args []interface{}
-
+// claimStatusWhere filters claims by the status types headquarters lists:
+// unacknowledged, pending or history (approved and rejected)
+func claimStatusWhere(statusType string) (string, error) {
 	switch statusType {
 	case "unacknowledged":
-		query = `
-			SELECT c.id, c.warranty_id, c.shop_id, s.shop_name, s.contact, c.status, c.rejection_reason, 
-			       c.date_settled, c.date_closed, c.customer_name, c.phone_number, c.email, c.car_plate, 
-			       c.created_at, c.updated_at
-			FROM claims c
-			LEFT JOIN shops s ON c.shop_id = s.id
-			WHERE c.status = 'unacknowledged'
-			ORDER BY c.created_at DESC
-		`
+		return `WHERE c.status = 'unacknowledged'`, nil
 	case "pending":
-		query = `
-			SELECT c.id, c.warranty_id, c.shop_id, s.shop_name, s.contact, c.status, c.rejection_reason, 
-			       c.date_settled, c.date_closed, c.customer_name, c.phone_number, c.email, c.car_plate, 
-			       c.created_at, c.updated_at
-			FROM claims c
-			LEFT JOIN shops s ON c.shop_id = s.id
-			WHERE c.status = 'pending'
-			ORDER BY c.created_at DESC
-		`
+		return `WHERE c.status = 'pending'`, nil
 	case "history":
-		query = `
-			SELECT c.id, c.warranty_id, c.shop_id, s.shop_name, s.contact, c.status, c.rejection_reason, 
-			       c.date_settled, c.date_closed, c.customer_name, c.phone_number, c.email, c.car_plate, 
-			       c.created_at, c.updated_at
-			FROM claims c
-			LEFT JOIN shops s ON c.shop_id = s.id
-			WHERE c.status IN ('approved', 'rejected')
-			ORDER BY c.created_at DESC
-		`
-	default:
-		return nil, fmt.Errorf("invalid status type: %s", statusType)
-	}
-
-	log.Printf("Executing SQL query: %s", query)
-
-	rows, err := db.Query(context.Background(), query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query claims: %v", err)
+		return `WHERE c.status IN ('approved', 'rejected')`, nil
 	}
-	defer rows.Close()
-
-	claims := []models.Claim{} // Initialize empty slice
-	for rows.Next() {
-		var claim models.Claim
-		var rejectionReason pgtype.Text
-		var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
-		var shopName, contact pgtype.Text
-
-		err := rows.Scan(
-			&claim.ID,
-			&claim.WarrantyID,
-			&claim.ShopID,
-			&shopName,
-			&contact,
-			&claim.Status,
-			&rejectionReason,
-			&dateSettled,
-			&dateClosed,
-			&claim.CustomerName,
-			&claim.PhoneNumber,
-			&claim.Email,
-			&claim.CarPlate,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan claim: %v", err)
-		}
-
-		// Check if shop exists (should not be null for valid claims)
-		if !shopName.Valid {
-			return nil, fmt.Errorf("shop not found for claim %s", claim.ID)
-		}
-
-		// Convert pgtype values to Go types
-		claim.ShopName = shopName.String
-		if contact.Valid {
-			claim.Contact = contact.String
-		}
-		if rejectionReason.Valid {
-			claim.RejectionReason = rejectionReason.String
-		}
-		if dateSettled.Valid {
-			claim.DateSettled = &dateSettled.Time
-		}
-		if dateClosed.Valid {
-			claim.DateClosed = &dateClosed.Time
-		}
-		if createdAt.Valid {
-			claim.CreatedAt = createdAt.Time
-		}
-		if updatedAt.Valid {
-			claim.UpdatedAt = updatedAt.Time
-		}
+	return "", fmt.Errorf("invalid status type: %s", statusType)
+}
 
-		claims = append(claims, claim)
+// GetClaimsByStatus retrieves claims based on status type
+func GetClaimsByStatus(ctx context.Context, statusType string) ([]models.Claim, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating claims: %v", err)
+	where, err := claimStatusWhere(statusType)
+	if err != nil {
+		return nil, err
 	}
 
-	return claims, nil
+	query := `SELECT ` + claimColumns + claimFrom + where + `
+		ORDER BY c.created_at DESC
+	`
+
+	slog.DebugContext(ctx, "querying claims by status", "status", statusType)
+
+	return queryClaims(ctx, query)
 }
 
 // AcceptClaim changes claim status to approved and adds tyre details
-func AcceptClaim(claimID string, tyreDetails []models.TyreDetail) (*models.Claim, error) {
+func AcceptClaim(ctx context.Context, claimID string, tyreDetails []models.TyreDetail) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
 	// Start transaction
-	tx, err := db.Begin(context.Background())
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %v", err)
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
 	// Update claim status to approved
 	updateClaimQuery := `
@@ -606,7 +466,7 @@ func AcceptClaim(claimID string, tyreDetails []models.TyreDetail) (*models.Claim
 		RETURNING id`
 
 	var claimIDResult string
-	err = tx.QueryRow(context.Background(), updateClaimQuery, claimID, models.ApprovedStatus).Scan(&claimIDResult)
+	err = tx.QueryRow(ctx, updateClaimQuery, claimID, models.ApprovedStatus).Scan(&claimIDResult)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, fmt.Errorf("claim not found or not in pending status")
@@ -623,7 +483,7 @@ func AcceptClaim(claimID string, tyreDetails []models.TyreDetail) (*models.Claim
 	for i := range tyreDetails {
 		var tyreID string
 		var createdAt time.Time
-		err = tx.QueryRow(context.Background(), insertTyreQuery,
+		err = tx.QueryRow(ctx, insertTyreQuery,
 			claimID,
 			tyreDetails[i].Brand,
 			tyreDetails[i].Size,
@@ -638,22 +498,22 @@ func AcceptClaim(claimID string, tyreDetails []models.TyreDetail) (*models.Claim
 	}
 
 	// Commit transaction
-	if err := tx.Commit(context.Background()); err != nil {
+	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	// Get updated claim with tyre details
-	return GetClaimWithTyreDetails(claimID)
+	return GetClaimWithTyreDetails(ctx, claimID)
 }
 
 // GetClaimWithTyreDetails retrieves a claim with its tyre details
-func GetClaimWithTyreDetails(claimID string) (*models.Claim, error) {
+func GetClaimWithTyreDetails(ctx context.Context, claimID string) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
 	// Get claim
-	claim, err := GetClaimByID(claimID)
+	claim, err := GetClaimByID(ctx, claimID)
 	if err != nil {
 		return nil, err
 	}
@@ -668,7 +528,7 @@ func GetClaimWithTyreDetails(claimID string) (*models.Claim, error) {
 		WHERE claim_id = $1
 		ORDER BY created_at ASC`
 
-	rows, err := db.Query(context.Background(), query, claimID)
+	rows, err := db.Query(ctx, query, claimID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tyre details: %v", err)
 	}
@@ -695,8 +555,67 @@ func GetClaimWithTyreDetails(claimID string) (*models.Claim, error) {
 	return claim, nil
 }
 
+// tyreDetailsJSON selects the tyre details of claim c as a JSON array, to be
+// scanned into []models.TyreDetail
+const tyreDetailsJSON = `
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'id', td.id, 'claim_id', td.claim_id, 'brand', td.brand, 'size', td.size,
+			'tread_pattern', td.tread_pattern, 'created_at', td.created_at
+		) ORDER BY td.created_at)
+		FROM tyre_details td
+		WHERE td.claim_id = c.id
+	), '[]')
+`
+
+// withExtraColumns lets a scan function for a fixed column list read a row
+// that has more columns after them, scanning those into extra
+type withExtraColumns struct {
+	pgx.Row
+	extra []any
+}
+
+func (r withExtraColumns) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.extra...)...)
+}
+
+// GetClaimInfo retrieves a claim with its shop, tyre details and tagged
+// warranty (nil if none) in a single query. It returns a nil claim if not
+// found.
+func GetClaimInfo(ctx context.Context, claimID string) (*models.Claim, *models.Warranty, error) {
+	if db == nil {
+		return nil, nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		SELECT ` + claimColumns + `, ` + tyreDetailsJSON + `, ` + warrantyColumns + `
+		` + claimFrom + `
+		LEFT JOIN warranties w ON w.id = c.warranty_id
+		WHERE c.id = $1`
+
+	var tyreDetails []models.TyreDetail
+	var warranty warrantyRow
+
+	claim, err := scanClaim(withExtraColumns{
+		Row:   db.QueryRow(ctx, query, claimID),
+		extra: append([]any{&tyreDetails}, warranty.dest()...),
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get claim info: %v", err)
+	}
+
+	if len(tyreDetails) > 0 {
+		claim.TyreDetails = tyreDetails
+	}
+
+	return claim, warranty.warranty(), nil
+}
+
 // RejectClaim changes claim status to rejected with a reason
-func RejectClaim(claimID string, reason string) (*models.Claim, error) {
+func RejectClaim(ctx context.Context, claimID string, reason string) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
@@ -707,57 +626,21 @@ func RejectClaim(claimID string, reason string) (*models.Claim, error) {
 		    rejection_reason = $3,
 		    updated_at = CURRENT_TIMESTAMP,
 		    date_settled = CURRENT_TIMESTAMP
-		WHERE id = $1 AND status = 'pending'
-		RETURNING id, warranty_id, shop_id, status, rejection_reason, 
-		          date_settled, date_closed,
-		          customer_name, phone_number, email, car_plate,
-		          created_at, updated_at`
-
-	var claim models.Claim
-	var warrantyID, rejectionReason pgtype.Text
-	var dateSettled, dateClosed pgtype.Timestamp
-
-	err := db.QueryRow(context.Background(), query, claimID, models.RejectedStatus, reason).Scan(
-		&claim.ID,
-		&warrantyID,
-		&claim.ShopID,
-		&claim.Status,
-		&rejectionReason,
-		&dateSettled,
-		&dateClosed,
-		&claim.CustomerName,
-		&claim.PhoneNumber,
-		&claim.Email,
-		&claim.CarPlate,
-		&claim.CreatedAt,
-		&claim.UpdatedAt,
-	)
+		WHERE id = $1 AND status = 'pending'`
 
+	tag, err := db.Exec(ctx, query, claimID, models.RejectedStatus, reason)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("claim not found or not in pending status")
-		}
 		return nil, fmt.Errorf("failed to update claim: %v", err)
 	}
-
-	if warrantyID.Valid {
-		claim.WarrantyID = &warrantyID.String
-	}
-	if rejectionReason.Valid {
-		claim.RejectionReason = rejectionReason.String
-	}
-	if dateSettled.Valid {
-		claim.DateSettled = &dateSettled.Time
-	}
-	if dateClosed.Valid {
-		claim.DateClosed = &dateClosed.Time
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("claim not found or not in pending status")
 	}
 
-	return &claim, nil
+	return GetClaimByID(ctx, claimID)
 }
 
 // CloseClaim updates the date_closed field of a claim
-func CloseClaim(claimID string) (*models.Claim, error) {
+func CloseClaim(ctx context.Context, claimID string) (*models.Claim, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
@@ -766,60 +649,17 @@ func CloseClaim(claimID string) (*models.Claim, error) {
 		UPDATE claims 
 		SET date_closed = CURRENT_TIMESTAMP,
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1 AND status IN ('approved', 'rejected')
-		RETURNING id, warranty_id, shop_id, status, rejection_reason, 
-		          date_settled, date_closed,
-		          customer_name, phone_number, email, car_plate,
-		          created_at, updated_at`
-
-	log.Printf("Executing SQL query: %s with params: [%s]", query, claimID)
+		WHERE id = $1 AND status IN ('approved', 'rejected')`
 
-	var claim models.Claim
-	var warrantyID, rejectionReason pgtype.Text
-	var dateSettled, dateClosed, createdAt, updatedAt pgtype.Timestamp
-
-	err := db.QueryRow(context.Background(), query, claimID).Scan(
-		&claim.ID,
-		&warrantyID,
-		&claim.ShopID,
-		&claim.Status,
-		&rejectionReason,
-		&dateSettled,
-		&dateClosed,
-		&claim.CustomerName,
-		&claim.PhoneNumber,
-		&claim.Email,
-		&claim.CarPlate,
-		&createdAt,
-		&updatedAt,
-	)
+	slog.DebugContext(ctx, "closing claim", "claim_id", claimID)
 
+	tag, err := db.Exec(ctx, query, claimID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("claim not found or not in approved/rejected status")
-		}
 		return nil, fmt.Errorf("failed to close claim: %v", err)
 	}
-
-	// Convert pgtype values to Go types
-	if warrantyID.Valid {
-		claim.WarrantyID = &warrantyID.String
-	}
-	if rejectionReason.Valid {
-		claim.RejectionReason = rejectionReason.String
-	}
-	if dateSettled.Valid {
-		claim.DateSettled = &dateSettled.Time
-	}
-	if dateClosed.Valid {
-		claim.DateClosed = &dateClosed.Time
-	}
-	if createdAt.Valid {
-		claim.CreatedAt = createdAt.Time
-	}
-	if updatedAt.Valid {
-		claim.UpdatedAt = updatedAt.Time
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("claim not found or not in approved/rejected status")
 	}
 
-	return &claim, nil
+	return GetClaimByID(ctx, claimID)
 }
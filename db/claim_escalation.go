@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tayaria-warranty-be/models"
+)
+
+// GetOverdueClaims returns the claims that have been in their status longer
+// than its SLA target at now and were not escalated since entering it,
+// longest overdue first
+func GetOverdueClaims(ctx context.Context, sla models.ClaimSLA, now time.Time) ([]models.Claim, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+	if len(sla) == 0 {
+		return []models.Claim{}, nil
+	}
+
+	statuses := make([]string, 0, len(sla))
+	for status := range sla {
+		statuses = append(statuses, string(status))
+	}
+	sort.Strings(statuses)
+
+	var conditions []string
+	var args []interface{}
+	for _, status := range statuses {
+		args = append(args, status, now.Add(-sla[models.ClaimStatus(status)]))
+		conditions = append(conditions, fmt.Sprintf("(c.status = $%d AND c.status_changed_at < $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + claimColumns + claimFrom + `
+		WHERE (` + strings.Join(conditions, " OR ") + `)
+		AND NOT EXISTS (
+		    SELECT 1 FROM claim_escalations e
+		    WHERE e.claim_id = c.id AND e.status = c.status AND e.status_changed_at = c.status_changed_at
+		)
+		ORDER BY c.status_changed_at ASC
+	`
+
+	claims, err := queryClaims(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Longest overdue first, whatever the status
+	due := func(claim models.Claim) time.Time {
+		return claim.StatusChangedAt.Add(sla[claim.Status])
+	}
+	sort.SliceStable(claims, func(i, j int) bool {
+		return due(claims[i]).Before(due(claims[j]))
+	})
+	return claims, nil
+}
+
+// RecordClaimEscalation records that an overdue claim is being escalated. It
+// returns false if it already was since the claim entered its status, e.g.
+// by another instance running the scheduler at the same time.
+func RecordClaimEscalation(ctx context.Context, claim models.Claim) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	tag, err := db.Exec(ctx, `
+		INSERT INTO claim_escalations (claim_id, status, status_changed_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (claim_id, status, status_changed_at) DO NOTHING
+	`, claim.ID, claim.Status, claim.StatusChangedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record claim escalation: %v", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseClaimEscalation forgets an escalation that could not be sent, so the
+// next run tries again
+func ReleaseClaimEscalation(ctx context.Context, claim models.Claim) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	_, err := db.Exec(ctx, `
+		DELETE FROM claim_escalations
+		WHERE claim_id = $1 AND status = $2 AND status_changed_at = $3
+	`, claim.ID, claim.Status, claim.StatusChangedAt)
+	if err != nil {
+		return fmt.Errorf("failed to release claim escalation: %v", err)
+	}
+	return nil
+}
+
+// GetEscalationRecipients returns the active users who can review claims and
+// have an email address
+func GetEscalationRecipients(ctx context.Context) ([]models.User, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	var roles []string
+	for _, role := range models.RolesWithPermission(models.PermReviewClaims) {
+		roles = append(roles, string(role))
+	}
+
+	query := `SELECT ` + userColumns + `
+		FROM users u
+		JOIN shops s ON u.shop_id = s.id
+		WHERE u.is_active AND u.email IS NOT NULL
+		AND u.role = ANY(string_to_array($1, ','))
+		ORDER BY u.username
+	`
+
+	rows, err := db.Query(ctx, query, strings.Join(roles, ","))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query escalation recipients: %v", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users = append(users, *user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %v", err)
+	}
+
+	return users, nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// insertInspections adds inspections of a claim within tx
+func insertInspections(ctx context.Context, tx pgx.Tx, claimID string, inspections []models.TyreInspection) error {
+	query := `
+		INSERT INTO claim_inspections (claim_id, tyre_position, tread_depth_mm, damage_type,
+		                               puncture_location, repairable, notes)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`
+
+	for _, inspection := range inspections {
+		_, err := tx.Exec(ctx, query,
+			claimID,
+			string(inspection.TyrePosition),
+			inspection.TreadDepthMM,
+			string(inspection.DamageType),
+			string(inspection.PunctureLocation),
+			inspection.Repairable,
+			inspection.Notes,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert inspection: %v", err)
+		}
+	}
+	return nil
+}
+
+// ReplaceClaimInspections replaces all inspections of a claim
+func ReplaceClaimInspections(ctx context.Context, claimID string, inspections []models.TyreInspection) ([]models.TyreInspection, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `DELETE FROM claim_inspections WHERE claim_id = $1`, claimID); err != nil {
+		return nil, fmt.Errorf("failed to clear inspections: %v", err)
+	}
+	if err = insertInspections(ctx, tx, claimID, inspections); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return GetClaimInspections(ctx, claimID)
+}
+
+// GetClaimInspections returns the inspections of a claim, oldest first
+func GetClaimInspections(ctx context.Context, claimID string) ([]models.TyreInspection, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		SELECT id, claim_id, tyre_position, tread_depth_mm::float8, damage_type,
+		       puncture_location, repairable, notes, created_at
+		FROM claim_inspections
+		WHERE claim_id = $1
+		ORDER BY created_at ASC, tyre_position ASC`
+
+	rows, err := db.Query(ctx, query, claimID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inspections: %v", err)
+	}
+	defer rows.Close()
+
+	inspections := []models.TyreInspection{}
+	for rows.Next() {
+		var inspection models.TyreInspection
+		var punctureLocation pgtype.Text
+		err := rows.Scan(
+			&inspection.ID,
+			&inspection.ClaimID,
+			&inspection.TyrePosition,
+			&inspection.TreadDepthMM,
+			&inspection.DamageType,
+			&punctureLocation,
+			&inspection.Repairable,
+			&inspection.Notes,
+			&inspection.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inspection: %v", err)
+		}
+		inspection.PunctureLocation = models.PunctureLocation(punctureLocation.String)
+		inspections = append(inspections, inspection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating inspections: %v", err)
+	}
+
+	return inspections, nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimPhotoColumns = `
+	id, claim_id, tyre_position, storage_key, thumbnail_key, content_type,
+	size_bytes, width, height, uploaded_by, created_at
+`
+
+func scanClaimPhoto(row pgx.Row) (*models.ClaimPhoto, error) {
+	var photo models.ClaimPhoto
+	var tyrePosition, uploadedBy pgtype.Text
+
+	err := row.Scan(
+		&photo.ID,
+		&photo.ClaimID,
+		&tyrePosition,
+		&photo.StorageKey,
+		&photo.ThumbnailKey,
+		&photo.ContentType,
+		&photo.SizeBytes,
+		&photo.Width,
+		&photo.Height,
+		&uploadedBy,
+		&photo.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	photo.TyrePosition = models.TyrePosition(tyrePosition.String)
+	photo.UploadedBy = uploadedBy.String
+	return &photo, nil
+}
+
+// CreateClaimPhoto records a photo whose files are already in storage
+func CreateClaimPhoto(ctx context.Context, photo models.ClaimPhoto) (*models.ClaimPhoto, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		INSERT INTO claim_photos (id, claim_id, tyre_position, storage_key, thumbnail_key,
+		                          content_type, size_bytes, width, height, uploaded_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		RETURNING ` + claimPhotoColumns
+
+	created, err := scanClaimPhoto(db.QueryRow(ctx, query,
+		photo.ID,
+		photo.ClaimID,
+		string(photo.TyrePosition),
+		photo.StorageKey,
+		photo.ThumbnailKey,
+		photo.ContentType,
+		photo.SizeBytes,
+		photo.Width,
+		photo.Height,
+		photo.UploadedBy,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create claim photo: %v", err)
+	}
+
+	return created, nil
+}
+
+// CountClaimPhotos returns how many photos a claim has
+func CountClaimPhotos(ctx context.Context, claimID string) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection not initialized")
+	}
+
+	var count int
+	err := db.QueryRow(ctx, `SELECT COUNT(*) FROM claim_photos WHERE claim_id = $1`, claimID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count claim photos: %v", err)
+	}
+	return count, nil
+}
+
+// GetClaimPhotos returns the photos of a claim, oldest first
+func GetClaimPhotos(ctx context.Context, claimID string) ([]models.ClaimPhoto, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `SELECT ` + claimPhotoColumns + ` FROM claim_photos WHERE claim_id = $1 ORDER BY created_at ASC`
+
+	rows, err := db.Query(ctx, query, claimID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claim photos: %v", err)
+	}
+	defer rows.Close()
+
+	photos := []models.ClaimPhoto{}
+	for rows.Next() {
+		photo, err := scanClaimPhoto(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan claim photo: %v", err)
+		}
+		photos = append(photos, *photo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claim photos: %v", err)
+	}
+
+	return photos, nil
+}
+
+// GetClaimPhoto returns a photo of the given claim, or nil if not found
+func GetClaimPhoto(ctx context.Context, claimID, photoID string) (*models.ClaimPhoto, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `SELECT ` + claimPhotoColumns + ` FROM claim_photos WHERE id = $1 AND claim_id = $2`
+
+	photo, err := scanClaimPhoto(db.QueryRow(ctx, query, photoID, claimID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get claim photo: %v", err)
+	}
+
+	return photo, nil
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"tayaria-warranty-be/models"
+)
+
+// EachClaimByStatus calls fn for every claim of a status type (see
+// GetClaimsByStatus) with its tyre details, newest first, reading the claims
+// one at a time instead of loading them all. It stops at the first error fn
+// returns.
+func EachClaimByStatus(ctx context.Context, statusType string, fn func(claim models.Claim) error) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	where, err := claimStatusWhere(statusType)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT ` + claimColumns + `, ` + tyreDetailsJSON + claimFrom + where + `
+		ORDER BY c.created_at DESC, c.id`
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query claims: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tyreDetails []models.TyreDetail
+		claim, err := scanClaim(withExtraColumns{Row: rows, extra: []any{&tyreDetails}})
+		if err != nil {
+			return fmt.Errorf("failed to scan claim: %v", err)
+		}
+		claim.TyreDetails = tyreDetails
+		if err := fn(*claim); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating claims: %v", err)
+	}
+	return nil
+}
+
+// EachWarranty calls fn for every warranty matching the filters of search
+// (paging is ignored), newest first, reading them one at a time. It stops at
+// the first error fn returns.
+func EachWarranty(ctx context.Context, search models.WarrantySearch, fn func(warranty models.Warranty) error) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	where, args := warrantySearchWhere(search)
+	query := `SELECT ` + warrantyColumns + ` FROM warranties w ` + where + `
+		ORDER BY w.created_at DESC, w.id`
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query warranties: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		warranty, err := scanWarranty(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan warranty: %v", err)
+		}
+		if err := fn(*warranty); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating warranties: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// Schema changes made after setup.sql live in db/migrations as numbered
+// .sql files (e.g. 001_add_users.sql). They are embedded in the binary and
+// applied in order; applied versions are recorded in schema_migrations.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	Version string
+	SQL     string
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		content, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{
+			Version: strings.TrimSuffix(entry.Name(), ".sql"),
+			SQL:     string(content),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func ensureMigrationsTable(ctx context.Context) error {
+	_, err := db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version VARCHAR(255) PRIMARY KEY,
+		    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+func appliedMigrations(ctx context.Context) (map[string]bool, error) {
+	rows, err := db.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %v", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// PendingMigrations returns the versions of embedded migrations that have not
+// been applied to the database yet
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only reads: before the first migration the table does not exist yet
+	// and everything is pending
+	var exists bool
+	if err := db.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations: %v", err)
+	}
+	applied := map[string]bool{}
+	if exists {
+		if applied, err = appliedMigrations(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := []string{}
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies all pending migrations, each in its own transaction
+func Migrate(ctx context.Context) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if err := ensureMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		slog.InfoContext(ctx, "applying migration", "version", m.Version)
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("failed to apply migration %s: %v", m.Version, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("failed to record migration %s: %v", m.Version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %v", m.Version, err)
+		}
+	}
+
+	return nil
+}
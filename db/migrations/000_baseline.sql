@@ -0,0 +1,2 @@
+-- Baseline: the initial schema (shops, warranties, claims, tyre_details) is
+-- created by setup.sql. Later schema changes are added here as numbered files.
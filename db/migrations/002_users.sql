@@ -0,0 +1,32 @@
+-- Per-user staff accounts. Shops no longer double as the login account; each
+-- existing shop login becomes the first user of that shop.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    shop_id UUID NOT NULL REFERENCES shops(id),
+    username VARCHAR(50) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(30) NOT NULL CHECK (role IN ('shop_staff', 'shop_manager', 'claims_reviewer', 'master_admin', 'auditor')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Accounts are locked after repeated failed logins
+    failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE TRIGGER update_users_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX IF NOT EXISTS idx_users_shop ON users(shop_id);
+
+-- Existing shop logins become users (bcrypt-hashed), keeping their access
+INSERT INTO users (shop_id, username, password_hash, name, role)
+SELECT id, username, crypt(password, gen_salt('bf', 10)), shop_name,
+       CASE WHEN role = 'master' THEN 'master_admin' ELSE 'shop_manager' END
+FROM shops
+ON CONFLICT (username) DO NOTHING;
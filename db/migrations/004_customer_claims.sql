@@ -0,0 +1,10 @@
+-- Customers can start a claim from the public site. Such claims wait in
+-- customer_submitted until the chosen shop accepts them into the normal flow.
+ALTER TABLE claims DROP CONSTRAINT IF EXISTS claims_status_check;
+ALTER TABLE claims ADD CONSTRAINT claims_status_check
+    CHECK (status IN ('customer_submitted', 'unacknowledged', 'pending', 'approved', 'rejected', 'closed'));
+
+ALTER TABLE claims ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE claims ADD COLUMN IF NOT EXISTS photo_urls TEXT[] NOT NULL DEFAULT '{}';
+-- Reference given to the customer to follow up on their claim
+ALTER TABLE claims ADD COLUMN IF NOT EXISTS tracking_ref VARCHAR(20) UNIQUE;
@@ -0,0 +1,90 @@
+-- Human-friendly claim references (TYR-2026-000123), numbered per year in
+-- Malaysian time. The counter row is locked by the upsert, so concurrent
+-- inserts never get the same number.
+CREATE TABLE IF NOT EXISTS claim_reference_counters (
+    year INTEGER PRIMARY KEY,
+    last_value INTEGER NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION next_claim_reference()
+RETURNS TEXT AS $$
+DECLARE
+    ref_year INTEGER := EXTRACT(YEAR FROM CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kuala_Lumpur');
+    seq INTEGER;
+BEGIN
+    INSERT INTO claim_reference_counters (year, last_value) VALUES (ref_year, 1)
+    ON CONFLICT (year) DO UPDATE SET last_value = claim_reference_counters.last_value + 1
+    RETURNING last_value INTO seq;
+    RETURN format('TYR-%s-%s', ref_year, lpad(seq::text, GREATEST(6, length(seq::text)), '0'));
+END;
+$$ LANGUAGE plpgsql;
+
+-- Number existing claims in the order they were created, without touching
+-- updated_at. References already given to customers are kept.
+ALTER TABLE claims DISABLE TRIGGER update_claims_updated_at;
+
+WITH numbered AS (
+    SELECT id,
+           EXTRACT(YEAR FROM created_at AT TIME ZONE 'Asia/Kuala_Lumpur')::INTEGER AS ref_year,
+           ROW_NUMBER() OVER (
+               PARTITION BY EXTRACT(YEAR FROM created_at AT TIME ZONE 'Asia/Kuala_Lumpur')
+               ORDER BY created_at, id
+           ) AS seq
+    FROM claims
+    WHERE tracking_ref IS NULL
+)
+UPDATE claims c
+SET tracking_ref = format('TYR-%s-%s', n.ref_year, lpad(n.seq::text, 6, '0'))
+FROM numbered n
+WHERE c.id = n.id;
+
+ALTER TABLE claims ENABLE TRIGGER update_claims_updated_at;
+
+INSERT INTO claim_reference_counters (year, last_value)
+SELECT split_part(tracking_ref, '-', 2)::INTEGER, MAX(split_part(tracking_ref, '-', 3)::INTEGER)
+FROM claims
+WHERE tracking_ref LIKE 'TYR-%'
+GROUP BY 1
+ON CONFLICT (year) DO UPDATE SET last_value = GREATEST(claim_reference_counters.last_value, EXCLUDED.last_value);
+
+ALTER TABLE claims ALTER COLUMN tracking_ref SET DEFAULT next_claim_reference();
+ALTER TABLE claims ALTER COLUMN tracking_ref SET NOT NULL;
+
+-- Status timeline shown to customers tracking their claim. Recorded by a
+-- trigger so every status change is captured whichever code path makes it.
+CREATE TABLE IF NOT EXISTS claim_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    claim_id UUID NOT NULL REFERENCES claims(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_claim_status_history_claim ON claim_status_history(claim_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_claim_status()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.status IS DISTINCT FROM OLD.status THEN
+        INSERT INTO claim_status_history (claim_id, status) VALUES (NEW.id, NEW.status);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER record_claim_status_change
+    AFTER INSERT OR UPDATE OF status ON claims
+    FOR EACH ROW
+    EXECUTE FUNCTION record_claim_status();
+
+-- Existing claims: when they were created and, if it has moved on since,
+-- their current status
+INSERT INTO claim_status_history (claim_id, status, changed_at)
+SELECT id,
+       CASE WHEN status = 'customer_submitted' THEN 'customer_submitted' ELSE 'unacknowledged' END,
+       created_at
+FROM claims;
+
+INSERT INTO claim_status_history (claim_id, status, changed_at)
+SELECT id, status, COALESCE(date_settled, updated_at)
+FROM claims
+WHERE status NOT IN ('customer_submitted', 'unacknowledged');
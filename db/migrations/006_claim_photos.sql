@@ -0,0 +1,19 @@
+-- Damage photos uploaded as claim evidence. The files live in storage; this
+-- table records where, and which tyre each photo shows.
+CREATE TABLE IF NOT EXISTS claim_photos (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    claim_id UUID NOT NULL REFERENCES claims(id) ON DELETE CASCADE,
+    tyre_position VARCHAR(20) CHECK (tyre_position IN ('front_left', 'front_right', 'rear_left', 'rear_right', 'spare')),
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    -- user_id from the uploader's token; not a foreign key since tokens from
+    -- before per-user accounts carry no user
+    uploaded_by TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_claim_photos_claim ON claim_photos(claim_id, created_at);
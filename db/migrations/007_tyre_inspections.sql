@@ -0,0 +1,14 @@
+-- What the shop found when inspecting each damaged tyre of a claim, checked
+-- against the warranty terms (tread depth above 6mm, damage repairable)
+CREATE TABLE IF NOT EXISTS claim_inspections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    claim_id UUID NOT NULL REFERENCES claims(id) ON DELETE CASCADE,
+    tyre_position VARCHAR(20) NOT NULL CHECK (tyre_position IN ('front_left', 'front_right', 'rear_left', 'rear_right', 'spare')),
+    tread_depth_mm NUMERIC(4, 1) NOT NULL CHECK (tread_depth_mm >= 0),
+    damage_type VARCHAR(30) NOT NULL CHECK (damage_type IN ('puncture', 'cut', 'sidewall_bulge', 'impact_break', 'tread_separation', 'other')),
+    puncture_location VARCHAR(20) CHECK (puncture_location IN ('tread', 'shoulder', 'sidewall')),
+    repairable BOOLEAN NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (claim_id, tyre_position)
+);
@@ -0,0 +1,20 @@
+-- Headquarters can void fraudulent registrations. Voided warranties are kept
+-- for the record but never count as valid.
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS voided_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS void_reason TEXT;
+
+-- Every correction, void and transfer made by headquarters
+CREATE TABLE IF NOT EXISTS warranty_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    warranty_id UUID NOT NULL REFERENCES warranties(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('update', 'void', 'transfer')),
+    -- user_id from the token of whoever made the change
+    changed_by TEXT,
+    -- {"field": {"from": ..., "to": ...}}
+    changes JSONB NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_warranty_audit_log_warranty ON warranty_audit_log(warranty_id, created_at);
+
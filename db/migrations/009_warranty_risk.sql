@@ -0,0 +1,15 @@
+-- Duplicate and fraud detection at registration. Registrations scoring high
+-- enough wait in a headquarters review queue (review_status = 'pending').
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS receipt_hash VARCHAR(64);
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS risk_score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS risk_reasons TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS review_status VARCHAR(20)
+    CHECK (review_status IN ('pending', 'cleared'));
+
+CREATE INDEX IF NOT EXISTS idx_warranties_receipt ON warranties(receipt);
+CREATE INDEX IF NOT EXISTS idx_warranties_receipt_hash ON warranties(receipt_hash);
+CREATE INDEX IF NOT EXISTS idx_warranties_review_pending ON warranties(risk_score DESC) WHERE review_status = 'pending';
+
+ALTER TABLE warranty_audit_log DROP CONSTRAINT IF EXISTS warranty_audit_log_action_check;
+ALTER TABLE warranty_audit_log ADD CONSTRAINT warranty_audit_log_action_check
+    CHECK (action IN ('update', 'void', 'transfer', 'clear'));
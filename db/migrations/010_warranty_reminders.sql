@@ -0,0 +1,10 @@
+-- Expiry reminders sent to customers. One row per warranty and reminder
+-- window (days before expiry), so a window is never sent twice.
+CREATE TABLE IF NOT EXISTS warranty_reminders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    warranty_id UUID NOT NULL REFERENCES warranties(id) ON DELETE CASCADE,
+    days_before INTEGER NOT NULL CHECK (days_before >= 0),
+    channel VARCHAR(20) NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (warranty_id, days_before)
+);
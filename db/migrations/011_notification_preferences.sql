@@ -0,0 +1,7 @@
+-- How each customer, identified by their phone number in E.164 form, wants
+-- to be notified about warranties and claims
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    phone_number VARCHAR(16) PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'sms', 'whatsapp')),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,24 @@
+-- Bulk imports of warranties registered before this system, from CSV.
+-- Each run (including dry runs) is recorded with its row errors so the error
+-- report can be downloaded later.
+CREATE TABLE IF NOT EXISTS warranty_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    filename TEXT NOT NULL,
+    -- user_id of the master user, or 'cli' for the import command
+    imported_by TEXT,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    duplicate_rows INTEGER NOT NULL DEFAULT 0,
+    error_rows INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- import_key identifies an imported warranty by plate, purchase date and
+-- phone, so importing the same rows again skips them. The import record is
+-- written after its rows, hence the deferred foreign key.
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS import_id UUID
+    REFERENCES warranty_imports(id) DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE warranties ADD COLUMN IF NOT EXISTS import_key VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warranties_import_key ON warranties(import_key);
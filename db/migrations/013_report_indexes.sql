@@ -0,0 +1,3 @@
+-- Reports filter claims and registrations by when they were made
+CREATE INDEX IF NOT EXISTS idx_claims_created_at ON claims(created_at);
+CREATE INDEX IF NOT EXISTS idx_warranties_created_at ON warranties(created_at);
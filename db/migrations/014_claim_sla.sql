@@ -0,0 +1,50 @@
+-- When each claim entered its current status, for SLA tracking. Set by a
+-- trigger so every status change is captured whichever code path makes it.
+ALTER TABLE claims ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing claims: the latest recorded change to their current status,
+-- without touching updated_at
+ALTER TABLE claims DISABLE TRIGGER update_claims_updated_at;
+
+UPDATE claims c
+SET status_changed_at = COALESCE(
+    (SELECT MAX(h.changed_at) FROM claim_status_history h WHERE h.claim_id = c.id AND h.status = c.status),
+    c.date_settled, c.updated_at, c.created_at, CURRENT_TIMESTAMP
+)
+WHERE c.status_changed_at IS NULL;
+
+ALTER TABLE claims ENABLE TRIGGER update_claims_updated_at;
+
+ALTER TABLE claims ALTER COLUMN status_changed_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE claims ALTER COLUMN status_changed_at SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION set_claim_status_changed_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status THEN
+        NEW.status_changed_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER set_claim_status_changed_at
+    BEFORE UPDATE OF status ON claims
+    FOR EACH ROW
+    EXECUTE FUNCTION set_claim_status_changed_at();
+
+CREATE INDEX IF NOT EXISTS idx_claims_status_changed_at ON claims(status, status_changed_at);
+
+-- Overdue claims escalated to headquarters, once per claim each time it
+-- enters a status
+CREATE TABLE IF NOT EXISTS claim_escalations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    claim_id UUID NOT NULL REFERENCES claims(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    status_changed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    escalated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (claim_id, status, status_changed_at)
+);
+
+-- Headquarters staff are emailed about overdue claims
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(100);
@@ -0,0 +1,3 @@
+-- Logins moved to users in 002; the old shops.password column still held the
+-- original plaintext passwords and is no longer read or written
+ALTER TABLE shops DROP COLUMN IF EXISTS password;
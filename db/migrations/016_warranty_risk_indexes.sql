@@ -0,0 +1,7 @@
+-- Indexes on the normalized plate and phone used by AssessWarrantyRisk, so
+-- scoring a registration looks up matching rows instead of normalizing every
+-- warranty. The expressions must stay identical to the ones in the query.
+CREATE INDEX IF NOT EXISTS idx_warranties_normalized_plate
+    ON warranties ((upper(regexp_replace(car_plate, '[^[:alnum:]]', '', 'g'))));
+CREATE INDEX IF NOT EXISTS idx_warranties_normalized_phone
+    ON warranties ((regexp_replace(phone_number, '[^0-9]', '', 'g')));
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetNotificationPreference records the channel a customer chose, replacing
+// any earlier choice. phone must be in E.164 form.
+func SetNotificationPreference(ctx context.Context, phone string, channel models.NotificationChannel) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO notification_preferences (phone_number, channel)
+		VALUES ($1, $2)
+		ON CONFLICT (phone_number) DO UPDATE
+		SET channel = EXCLUDED.channel, updated_at = CURRENT_TIMESTAMP
+	`, phone, string(channel))
+	if err != nil {
+		return fmt.Errorf("failed to save notification preference: %v", err)
+	}
+	return nil
+}
+
+// GetNotificationPreference returns the channel a customer chose, or "" if
+// they never chose one. phone must be in E.164 form.
+func GetNotificationPreference(ctx context.Context, phone string) (models.NotificationChannel, error) {
+	if db == nil {
+		return "", fmt.Errorf("database connection not initialized")
+	}
+
+	var channel string
+	err := db.QueryRow(ctx, `SELECT channel FROM notification_preferences WHERE phone_number = $1`, phone).Scan(&channel)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get notification preference: %v", err)
+	}
+	return models.NotificationChannel(channel), nil
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// queryFuncs take (ctx, query, args...); with the simple protocol Postgres
+// rejects a query whose arguments do not match its placeholders
+var queryFuncs = map[string]bool{
+	"Query":           true,
+	"QueryRow":        true,
+	"Exec":            true,
+	"queryClaims":     true,
+	"queryWarranties": true,
+}
+
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+// TestQueryArgumentsMatchPlaceholders checks every query in this package
+// whose text is known statically: the number of arguments passed must equal
+// the highest $N placeholder. Queries built at run time or called with
+// args... are skipped.
+func TestQueryArgumentsMatchPlaceholders(t *testing.T) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []*ast.File
+	for _, path := range paths {
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	consts := packageStringConsts(files)
+	checked := 0
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || call.Ellipsis.IsValid() || len(call.Args) < 2 || !queryFuncs[calleeName(call)] {
+					return true
+				}
+				query, ok := stringValue(call.Args[1], fn.Body, call.Pos(), consts)
+				if !ok {
+					return true
+				}
+				checked++
+				want := maxPlaceholder(query)
+				if got := len(call.Args) - 2; got != want {
+					t.Errorf("%s: %s passes %d arguments for placeholders up to $%d",
+						fset.Position(call.Pos()), fn.Name.Name, got, want)
+				}
+				return true
+			})
+		}
+	}
+
+	if checked == 0 {
+		t.Fatal("no queries were checked")
+	}
+}
+
+func calleeName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+func maxPlaceholder(query string) int {
+	max := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatch(query, -1) {
+		if n, _ := strconv.Atoi(match[1]); n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// packageStringConsts collects package-level string constants and string
+// variables initialized from literals, such as column lists
+func packageStringConsts(files []*ast.File) map[string]string {
+	exprs := map[string]ast.Expr{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || (gen.Tok != token.CONST && gen.Tok != token.VAR) {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value, ok := spec.(*ast.ValueSpec)
+				if !ok || len(value.Names) != len(value.Values) {
+					continue
+				}
+				for i, name := range value.Names {
+					exprs[name.Name] = value.Values[i]
+				}
+			}
+		}
+	}
+
+	consts := map[string]string{}
+	var resolve func(ast.Expr) (string, bool)
+	resolve = func(expr ast.Expr) (string, bool) {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			if e.Kind != token.STRING {
+				return "", false
+			}
+			s, err := strconv.Unquote(e.Value)
+			return s, err == nil
+		case *ast.BinaryExpr:
+			if e.Op != token.ADD {
+				return "", false
+			}
+			left, ok := resolve(e.X)
+			if !ok {
+				return "", false
+			}
+			right, ok := resolve(e.Y)
+			return left + right, ok
+		case *ast.ParenExpr:
+			return resolve(e.X)
+		case *ast.Ident:
+			if value, ok := exprs[e.Name]; ok {
+				return resolve(value)
+			}
+		}
+		return "", false
+	}
+	for name, expr := range exprs {
+		if s, ok := resolve(expr); ok {
+			consts[name] = s
+		}
+	}
+	return consts
+}
+
+// stringValue evaluates a query argument made of string literals, package
+// constants and a local variable assigned exactly once before the call
+func stringValue(expr ast.Expr, body *ast.BlockStmt, at token.Pos, consts map[string]string) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(e.Value)
+		return s, err == nil
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		left, ok := stringValue(e.X, body, at, consts)
+		if !ok {
+			return "", false
+		}
+		right, ok := stringValue(e.Y, body, at, consts)
+		return left + right, ok
+	case *ast.ParenExpr:
+		return stringValue(e.X, body, at, consts)
+	case *ast.Ident:
+		// Only locals declared with := resolve to an assignment; package
+		// names from other files are not resolved by the parser at all
+		if e.Obj == nil {
+			s, ok := consts[e.Name]
+			return s, ok
+		}
+		if _, ok := e.Obj.Decl.(*ast.AssignStmt); !ok {
+			s, ok := consts[e.Name]
+			return s, ok
+		}
+		value, ok := singleAssignment(e.Name, body, at)
+		if !ok {
+			return "", false
+		}
+		return stringValue(value, body, value.Pos(), consts)
+	}
+	return "", false
+}
+
+// singleAssignment returns the value assigned to name in body before at, if
+// it is assigned exactly once there and never modified afterwards
+func singleAssignment(name string, body *ast.BlockStmt, at token.Pos) (ast.Expr, bool) {
+	var value ast.Expr
+	assignments := 0
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		for i, lhs := range assign.Lhs {
+			ident, ok := lhs.(*ast.Ident)
+			if !ok || ident.Name != name {
+				continue
+			}
+			assignments++
+			if assign.Pos() < at && len(assign.Rhs) == len(assign.Lhs) && assign.Tok != token.ADD_ASSIGN {
+				value = assign.Rhs[i]
+			} else {
+				value = nil
+			}
+		}
+		return true
+	})
+	return value, assignments == 1 && value != nil
+}
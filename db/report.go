@@ -0,0 +1,340 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"tayaria-warranty-be/models"
+)
+
+// claimReportWhere builds the WHERE clause and arguments limiting claims to
+// those made within filter, optionally at one shop
+func claimReportWhere(filter models.ReportFilter) (string, []interface{}) {
+	conditions := []string{"c.created_at >= $1", "c.created_at < $2"}
+	args := []interface{}{filter.Start(), filter.End()}
+	if filter.ShopID != "" {
+		args = append(args, filter.ShopID)
+		conditions = append(conditions, fmt.Sprintf("c.shop_id = $%d", len(args)))
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetClaimsByShopMonth counts the claims made at each shop in each month of
+// the filter, months taken in the business timezone
+func GetClaimsByShopMonth(ctx context.Context, filter models.ReportFilter) ([]models.ShopMonthClaims, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	where, args := claimReportWhere(filter)
+	args = append(args, models.BusinessTimezone())
+	query := fmt.Sprintf(`
+		SELECT c.shop_id, COALESCE(s.shop_name, ''),
+		       date_trunc('month', c.created_at AT TIME ZONE $%d)::date AS month,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE c.status = 'approved'),
+		       COUNT(*) FILTER (WHERE c.status = 'rejected')
+		FROM claims c
+		LEFT JOIN shops s ON c.shop_id = s.id
+		%s
+		GROUP BY c.shop_id, s.shop_name, month
+		ORDER BY month, s.shop_name, c.shop_id`, len(args), where)
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claims by shop: %v", err)
+	}
+	defer rows.Close()
+
+	counts := []models.ShopMonthClaims{}
+	for rows.Next() {
+		var count models.ShopMonthClaims
+		if err := rows.Scan(&count.ShopID, &count.ShopName, &count.Month, &count.Claims, &count.Approved, &count.Rejected); err != nil {
+			return nil, fmt.Errorf("failed to scan claims by shop: %v", err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claims by shop: %v", err)
+	}
+
+	return counts, nil
+}
+
+// GetClaimOutcomes summarizes the status of the claims made within the
+// filter and how long deciding them took
+func GetClaimOutcomes(ctx context.Context, filter models.ReportFilter) (*models.ClaimOutcomes, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	where, args := claimReportWhere(filter)
+	query := `
+		SELECT c.status, COUNT(*),
+		       COUNT(c.date_settled),
+		       COALESCE(SUM(EXTRACT(EPOCH FROM c.date_settled - c.created_at)), 0)::float8,
+		       COUNT(c.date_closed),
+		       COALESCE(SUM(EXTRACT(EPOCH FROM c.date_closed - c.created_at)), 0)::float8
+		FROM claims c
+		` + where + `
+		GROUP BY c.status`
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claim outcomes: %v", err)
+	}
+	defer rows.Close()
+
+	outcomes := &models.ClaimOutcomes{ByStatus: map[models.ClaimStatus]int{}}
+	settled, closed := 0, 0
+	settleSeconds, closeSeconds := 0.0, 0.0
+	for rows.Next() {
+		var status models.ClaimStatus
+		var count, settledCount, closedCount int
+		var settledSeconds, closedSeconds float64
+		if err := rows.Scan(&status, &count, &settledCount, &settledSeconds, &closedCount, &closedSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan claim outcomes: %v", err)
+		}
+		outcomes.Total += count
+		outcomes.ByStatus[status] = count
+		if status == models.ApprovedStatus || status == models.RejectedStatus {
+			settled += settledCount
+			settleSeconds += settledSeconds
+			closed += closedCount
+			closeSeconds += closedSeconds
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claim outcomes: %v", err)
+	}
+
+	outcomes.Approved = outcomes.ByStatus[models.ApprovedStatus]
+	outcomes.Rejected = outcomes.ByStatus[models.RejectedStatus]
+	if decided := outcomes.Approved + outcomes.Rejected; decided > 0 {
+		outcomes.ApprovalRate = float64(outcomes.Approved) / float64(decided)
+		outcomes.RejectionRate = float64(outcomes.Rejected) / float64(decided)
+	}
+	if settled > 0 {
+		hours := settleSeconds / float64(settled) / 3600
+		outcomes.AvgHoursToSettle = &hours
+	}
+	if closed > 0 {
+		hours := closeSeconds / float64(closed) / 3600
+		outcomes.AvgHoursToClose = &hours
+	}
+
+	return outcomes, nil
+}
+
+// GetTopRejectionReasons returns the most common reasons claims made within
+// the filter were rejected, most common first
+func GetTopRejectionReasons(ctx context.Context, filter models.ReportFilter, limit int) ([]models.RejectionReasonCount, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	where, args := claimReportWhere(filter)
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT MIN(btrim(c.rejection_reason)), COUNT(*)
+		FROM claims c
+		%s AND c.status = 'rejected' AND btrim(COALESCE(c.rejection_reason, '')) <> ''
+		GROUP BY lower(btrim(c.rejection_reason))
+		ORDER BY COUNT(*) DESC, 1
+		LIMIT $%d`, where, len(args))
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejection reasons: %v", err)
+	}
+	defer rows.Close()
+
+	reasons := []models.RejectionReasonCount{}
+	for rows.Next() {
+		var reason models.RejectionReasonCount
+		if err := rows.Scan(&reason.Reason, &reason.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan rejection reasons: %v", err)
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rejection reasons: %v", err)
+	}
+
+	return reasons, nil
+}
+
+// GetTyresReplaced counts the tyres replaced under approved claims made
+// within the filter by brand and size, most replaced first. Brands and sizes
+// are compared ignoring case and spaces.
+func GetTyresReplaced(ctx context.Context, filter models.ReportFilter) ([]models.TyreCount, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	where, args := claimReportWhere(filter)
+	query := `
+		SELECT upper(btrim(t.brand)) AS brand, upper(replace(t.size, ' ', '')) AS size, COUNT(*)
+		FROM tyre_details t
+		JOIN claims c ON t.claim_id = c.id
+		` + where + ` AND c.status = 'approved'
+		GROUP BY 1, 2
+		ORDER BY COUNT(*) DESC, 1, 2`
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tyres replaced: %v", err)
+	}
+	defer rows.Close()
+
+	tyres := []models.TyreCount{}
+	for rows.Next() {
+		var tyre models.TyreCount
+		if err := rows.Scan(&tyre.Brand, &tyre.Size, &tyre.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tyres replaced: %v", err)
+		}
+		tyres = append(tyres, tyre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tyres replaced: %v", err)
+	}
+
+	return tyres, nil
+}
+
+// GetWarrantyRegistrations counts the warranties registered within the
+// filter per interval, including intervals with none. Bulk imported
+// warranties are left out, as they were registered before this system.
+func GetWarrantyRegistrations(ctx context.Context, filter models.ReportFilter, interval models.ReportInterval) ([]models.RegistrationCount, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+	if !interval.IsValid() {
+		return nil, fmt.Errorf("invalid report interval: %s", interval)
+	}
+
+	query := `
+		WITH periods AS (
+		    SELECT generate_series(date_trunc($3, $4::date::timestamp), $5::date::timestamp, ('1 ' || $3)::interval)::date AS period
+		)
+		SELECT p.period, COUNT(w.id), COUNT(w.voided_at)
+		FROM periods p
+		LEFT JOIN warranties w
+		    ON date_trunc($3, w.created_at AT TIME ZONE $6)::date = p.period
+		    AND w.created_at >= $1 AND w.created_at < $2
+		    AND w.import_id IS NULL
+		GROUP BY p.period
+		ORDER BY p.period`
+
+	rows, err := db.Query(ctx, query, filter.Start(), filter.End(), string(interval), filter.From, filter.To, models.BusinessTimezone())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query warranty registrations: %v", err)
+	}
+	defer rows.Close()
+
+	counts := []models.RegistrationCount{}
+	for rows.Next() {
+		var count models.RegistrationCount
+		if err := rows.Scan(&count.Period, &count.Registrations, &count.Voided); err != nil {
+			return nil, fmt.Errorf("failed to scan warranty registrations: %v", err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating warranty registrations: %v", err)
+	}
+
+	return counts, nil
+}
+
+// GetClaimsAwaitingClosure returns a shop's decided (approved or rejected)
+// claims it has not closed yet, longest waiting first
+func GetClaimsAwaitingClosure(ctx context.Context, shopID string) ([]models.Claim, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `SELECT ` + claimColumns + claimFrom + `
+		WHERE c.shop_id = $1
+		AND c.status IN ('approved', 'rejected')
+		AND c.date_closed IS NULL
+		ORDER BY c.date_settled ASC NULLS FIRST, c.created_at ASC
+	`
+
+	return queryClaims(ctx, query, shopID)
+}
+
+// GetClaimSLAReport measures each status with an SLA target against it,
+// counting each time a claim entered the status within the filter. Time in
+// a status runs until the claim moved on, or until now if it has not.
+func GetClaimSLAReport(ctx context.Context, filter models.ReportFilter, sla models.ClaimSLA, now time.Time) ([]models.SLAStatusReport, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+	if len(sla) == 0 {
+		return []models.SLAStatusReport{}, nil
+	}
+
+	args := []interface{}{filter.Start(), filter.End(), now, filter.ShopID}
+	var targets []string
+	for status, target := range sla {
+		args = append(args, string(status), target.Seconds())
+		targets = append(targets, fmt.Sprintf("($%d, $%d::float8)", len(args)-1, len(args)))
+	}
+
+	query := `
+		WITH spans AS (
+		    SELECT h.claim_id, h.status, h.changed_at AS entered_at,
+		           LEAD(h.changed_at) OVER (PARTITION BY h.claim_id ORDER BY h.changed_at) AS left_at
+		    FROM claim_status_history h
+		),
+		targets (status, target_seconds) AS (
+		    VALUES ` + strings.Join(targets, ", ") + `
+		)
+		SELECT t.status, t.target_seconds,
+		       COUNT(s.claim_id),
+		       COUNT(s.claim_id) FILTER (WHERE EXTRACT(EPOCH FROM COALESCE(s.left_at, $3::timestamptz) - s.entered_at) > t.target_seconds),
+		       COUNT(s.claim_id) FILTER (WHERE s.left_at IS NULL),
+		       COUNT(s.claim_id) FILTER (WHERE s.left_at IS NULL AND EXTRACT(EPOCH FROM $3::timestamptz - s.entered_at) > t.target_seconds),
+		       (AVG(EXTRACT(EPOCH FROM s.left_at - s.entered_at)) FILTER (WHERE s.left_at IS NOT NULL) / 3600)::float8
+		FROM targets t
+		LEFT JOIN (
+		    spans s JOIN claims c ON c.id = s.claim_id AND ($4 = '' OR c.shop_id::text = $4)
+		) ON s.status = t.status AND s.entered_at >= $1 AND s.entered_at < $2
+		GROUP BY t.status, t.target_seconds
+		ORDER BY t.status`
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claim SLA report: %v", err)
+	}
+	defer rows.Close()
+
+	reports := []models.SLAStatusReport{}
+	for rows.Next() {
+		var report models.SLAStatusReport
+		var targetSeconds float64
+		if err := rows.Scan(&report.Status, &targetSeconds, &report.Entered, &report.Breached, &report.Open,
+			&report.OpenBreached, &report.AvgHoursInStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan claim SLA report: %v", err)
+		}
+		report.TargetHours = targetSeconds / 3600
+		if report.Entered > 0 {
+			report.BreachRate = float64(report.Breached) / float64(report.Entered)
+		}
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claim SLA report: %v", err)
+	}
+
+	return reports, nil
+}
@@ -3,7 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/jackc/pgx/v5"
@@ -29,12 +29,12 @@ func Init() error {
 	}
 
 	// Test the connection
-	log.Printf("Testing database connection...")
+	slog.Info("testing database connection")
 	if err := db.Ping(context.Background()); err != nil {
 		return fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	log.Printf("Successfully connected to database (using pool)")
+	slog.Info("connected to database", "max_conns", cfg.MaxConns)
 	return nil
 }
 
@@ -43,3 +43,19 @@ func Close() {
 		db.Close()
 	}
 }
+
+// Ping checks that the pool can reach the database
+func Ping(ctx context.Context) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+	return db.Ping(ctx)
+}
+
+// Stats returns pool statistics, or nil before Init
+func Stats() *pgxpool.Stat {
+	if db == nil {
+		return nil
+	}
+	return db.Stat()
+}
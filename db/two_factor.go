@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"tayaria-warranty-be/utils"
+)
+
+// SetPendingTOTPSecret stores a new secret for a user who is enrolling. It is
+// not enforced until EnableTOTP confirms the user can generate codes.
+func SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	_, err := db.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to store TOTP secret: %v", err)
+	}
+	return nil
+}
+
+// EnableTOTP turns on 2FA after the first code was verified at step, and
+// replaces the user's recovery codes with the given bcrypt hashes
+func EnableTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET totp_enabled = TRUE, totp_last_step = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable TOTP: %v", err)
+	}
+
+	if _, err = tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear recovery codes: %v", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err = tx.Exec(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("failed to store recovery code: %v", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	slog.InfoContext(ctx, "two-factor authentication enabled", "user_id", userID)
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for new bcrypt hashes
+func ReplaceRecoveryCodes(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear recovery codes: %v", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err = tx.Exec(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("failed to store recovery code: %v", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false if
+// a code for this or a later step was already used, so each code works once.
+func UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	tag, err := db.Exec(ctx, `
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND totp_last_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %v", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode marks a matching unused recovery code as used and reports
+// whether one was found
+func UseRecoveryCode(ctx context.Context, userID, code string) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT id, code_hash FROM user_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to query recovery codes: %v", err)
+	}
+
+	var matchedID string
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan recovery code: %v", err)
+		}
+		if matchedID == "" && utils.CheckPassword(hash, code) {
+			matchedID = id
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("error iterating recovery codes: %v", err)
+	}
+	if matchedID == "" {
+		return false, nil
+	}
+
+	tag, err := db.Exec(ctx, `
+		UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND used_at IS NULL
+	`, matchedID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark recovery code used: %v", err)
+	}
+
+	slog.WarnContext(ctx, "recovery code used", "user_id", userID)
+	return tag.RowsAffected() == 1, nil
+}
+
+// ResetTwoFactor removes a user's 2FA secret and recovery codes, e.g. after a
+// lost phone. Users whose role requires 2FA must enroll again at next login.
+func ResetTwoFactor(ctx context.Context, userID string) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset TOTP: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if _, err = tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return false, fmt.Errorf("failed to clear recovery codes: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	slog.InfoContext(ctx, "two-factor authentication reset", "user_id", userID)
+	return true, nil
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const userColumns = `
+	u.id, u.shop_id, s.shop_name, u.username, u.password_hash, u.name, u.email, u.role, u.is_active,
+	u.failed_login_attempts, u.locked_until, u.totp_secret, u.totp_enabled, u.totp_last_step,
+	u.created_at, u.updated_at
+`
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	var lockedUntil pgtype.Timestamptz
+	var totpSecret, email pgtype.Text
+
+	err := row.Scan(
+		&user.ID,
+		&user.ShopID,
+		&user.ShopName,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Name,
+		&email,
+		&user.Role,
+		&user.IsActive,
+		&user.FailedLoginAttempts,
+		&lockedUntil,
+		&totpSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if totpSecret.Valid {
+		user.TOTPSecret = totpSecret.String
+	}
+	user.Email = email.String
+	return &user, nil
+}
+
+// GetUserByUsername retrieves a user by username, returning nil if not found
+func GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `SELECT ` + userColumns + `
+		FROM users u
+		JOIN shops s ON u.shop_id = s.id
+		WHERE u.username = $1
+	`
+
+	user, err := scanUser(db.QueryRow(ctx, query, username))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	return user, nil
+}
+
+// GetUserByID retrieves a user by ID, returning nil if not found
+func GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `SELECT ` + userColumns + `
+		FROM users u
+		JOIN shops s ON u.shop_id = s.id
+		WHERE u.id = $1
+	`
+
+	user, err := scanUser(db.QueryRow(ctx, query, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	return user, nil
+}
+
+// GetUsers lists users, limited to one shop when shopID is not empty
+func GetUsers(ctx context.Context, shopID string) ([]models.User, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `SELECT ` + userColumns + `
+		FROM users u
+		JOIN shops s ON u.shop_id = s.id
+		WHERE ($1 = '' OR u.shop_id::text = $1)
+		ORDER BY s.shop_name, u.username
+	`
+
+	rows, err := db.Query(ctx, query, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users = append(users, *user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %v", err)
+	}
+
+	return users, nil
+}
+
+// CreateUser inserts a new user. passwordHash is the bcrypt hash of the
+// requested password.
+func CreateUser(ctx context.Context, req models.CreateUserRequest, passwordHash string) (*models.User, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	var userID string
+	err := db.QueryRow(ctx, `
+		INSERT INTO users (shop_id, username, password_hash, name, role, email)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		RETURNING id
+	`, req.ShopID, req.Username, passwordHash, req.Name, req.Role, req.Email).Scan(&userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	slog.InfoContext(ctx, "user created", "user_id", userID, "shop_id", req.ShopID, "role", req.Role)
+	return GetUserByID(ctx, userID)
+}
+
+// UpdateUser applies the non-nil fields of req. passwordHash replaces the
+// password when not empty.
+func UpdateUser(ctx context.Context, userID string, req models.UpdateUserRequest, passwordHash string) (*models.User, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		UPDATE users
+		SET name = COALESCE($2, name),
+		    role = COALESCE($3, role),
+		    is_active = COALESCE($4, is_active),
+		    password_hash = COALESCE(NULLIF($5, ''), password_hash),
+		    email = CASE WHEN $6::text IS NULL THEN email ELSE NULLIF($6, '') END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	tag, err := db.Exec(ctx, query, userID, req.Name, req.Role, req.IsActive, passwordHash, req.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil
+	}
+
+	slog.InfoContext(ctx, "user updated", "user_id", userID)
+	return GetUserByID(ctx, userID)
+}
+
+// RecordFailedLogin increments the failed login counter for a user and locks
+// the account for lockoutDuration once maxAttempts is reached. It returns the
+// time the account is locked until, or nil if it is not locked.
+func RecordFailedLogin(ctx context.Context, userID string, maxAttempts int, lockoutDuration time.Duration) (*time.Time, error) {
+	// An expired lockout starts a fresh count
+	query := `
+		WITH attempt AS (
+		    SELECT id,
+		           CASE WHEN locked_until <= CURRENT_TIMESTAMP THEN 1
+		                ELSE failed_login_attempts + 1
+		           END AS attempts
+		    FROM users
+		    WHERE id = $1
+		)
+		UPDATE users u
+		SET failed_login_attempts = a.attempts,
+		    locked_until = CASE
+		        WHEN a.attempts >= $2 THEN CURRENT_TIMESTAMP + make_interval(secs => $3)
+		        ELSE NULL
+		    END
+		FROM attempt a
+		WHERE u.id = a.id
+		RETURNING u.locked_until
+	`
+
+	var lockedUntil pgtype.Timestamptz
+	err := db.QueryRow(ctx, query, userID, maxAttempts, lockoutDuration.Seconds()).Scan(&lockedUntil)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record failed login", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	if !lockedUntil.Valid || lockedUntil.Time.Before(time.Now()) {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// ResetFailedLogins clears the failed login counter and any lockout after a
+// successful login
+func ResetFailedLogins(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $1 AND (failed_login_attempts <> 0 OR locked_until IS NOT NULL)
+	`
+
+	if _, err := db.Exec(ctx, query, userID); err != nil {
+		slog.ErrorContext(ctx, "failed to reset failed logins", "user_id", userID, "error", err)
+		return err
+	}
+	return nil
+}
@@ -3,7 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"tayaria-warranty-be/models"
 
@@ -12,14 +12,117 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// normalizedCarPlate is w.car_plate without spaces or punctuation, upper-cased
+// like models.NormalizeCarPlate. Plates are compared this way so "wxy 1234"
+// and "WXY1234" are the same car; migration 016 indexes the expression.
+const normalizedCarPlate = `upper(regexp_replace(w.car_plate, '[^[:alnum:]]', '', 'g'))`
+
+const warrantyColumns = `
+	w.id, w.name, w.phone_number, w.email, w.purchase_date, w.expiry_date, w.car_plate, w.receipt,
+	w.created_at, w.updated_at, w.voided_at, w.void_reason, w.risk_score, w.risk_reasons, w.review_status
+`
+
+// warrantyRow holds the scanned values of warrantyColumns. Every column is
+// nullable so the columns can also be read from a LEFT JOIN.
+type warrantyRow struct {
+	id, name, phoneNumber, email, carPlate, receipt, voidReason, reviewStatus pgtype.Text
+	purchaseDate, expiryDate                                                  pgtype.Date
+	createdAt, updatedAt                                                      pgtype.Timestamp
+	voidedAt                                                                  pgtype.Timestamptz
+	riskScore                                                                 pgtype.Int4
+	riskReasons                                                               []string
+}
+
+func (r *warrantyRow) dest() []any {
+	return []any{
+		&r.id, &r.name, &r.phoneNumber, &r.email, &r.purchaseDate, &r.expiryDate, &r.carPlate, &r.receipt,
+		&r.createdAt, &r.updatedAt, &r.voidedAt, &r.voidReason, &r.riskScore, &r.riskReasons, &r.reviewStatus,
+	}
+}
+
+// warranty converts the scanned values, returning nil for an all-NULL row
+func (r *warrantyRow) warranty() *models.Warranty {
+	if !r.id.Valid {
+		return nil
+	}
+
+	// Convert pgtype values to Go types
+	warranty := &models.Warranty{
+		ID:           r.id.String,
+		Name:         r.name.String,
+		PhoneNumber:  r.phoneNumber.String,
+		Email:        r.email.String,
+		CarPlate:     r.carPlate.String,
+		Receipt:      r.receipt.String,
+		VoidReason:   r.voidReason.String,
+		RiskScore:    int(r.riskScore.Int32),
+		RiskReasons:  r.riskReasons,
+		ReviewStatus: models.WarrantyReviewStatus(r.reviewStatus.String),
+	}
+	if warranty.RiskReasons == nil {
+		warranty.RiskReasons = []string{}
+	}
+	if r.voidedAt.Valid {
+		warranty.VoidedAt = &r.voidedAt.Time
+	}
+	if r.purchaseDate.Valid {
+		warranty.PurchaseDate = models.DateOf(r.purchaseDate.Time)
+	}
+	if r.expiryDate.Valid {
+		warranty.ExpiryDate = models.DateOf(r.expiryDate.Time)
+	}
+	if r.createdAt.Valid {
+		warranty.CreatedAt = r.createdAt.Time
+	}
+	if r.updatedAt.Valid {
+		warranty.UpdatedAt = r.updatedAt.Time
+	}
+	return warranty
+}
+
+func scanWarranty(row pgx.Row) (*models.Warranty, error) {
+	var r warrantyRow
+	if err := row.Scan(r.dest()...); err != nil {
+		return nil, err
+	}
+	return r.warranty(), nil
+}
+
+// queryWarranties runs a query selecting warrantyColumns and scans every row
+func queryWarranties(ctx context.Context, query string, args ...interface{}) ([]models.Warranty, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query warranties: %v", err)
+	}
+	defer rows.Close()
+
+	var warranties []models.Warranty
+	for rows.Next() {
+		warranty, err := scanWarranty(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warranty: %v", err)
+		}
+		warranties = append(warranties, *warranty)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating warranties: %v", err)
+	}
+
+	return warranties, nil
+}
+
+// placeholderReceiptURL stands in for registrations without a receipt link
+const placeholderReceiptURL = "https://placeholder.com/receipt.pdf"
+
 // CreateWarranty creates a new warranty in the database
-func CreateWarranty(warranty models.CreateWarrantyRequest) (*models.Warranty, error) {
+func CreateWarranty(ctx context.Context, warranty models.CreateWarrantyRequest) (*models.Warranty, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
-	// Calculate expiry date (6 months from purchase date)
-	expiryDate := warranty.PurchaseDate.AddDate(0, 6, 0)
+	// Cover ends on the same day WarrantyMonths later, or that month's last day
+	expiryDate := warranty.PurchaseDate.AddMonths(models.WarrantyMonths)
 
 	// Generate UUID for warranty ID
 	warrantyID := uuid.New().String()
@@ -28,176 +131,95 @@ func CreateWarranty(warranty models.CreateWarrantyRequest) (*models.Warranty, er
 	// For now, use a placeholder URL
 	receiptURL := warranty.Receipt
 	if receiptURL == "" {
-		receiptURL = "https://placeholder.com/receipt.pdf"
+		receiptURL = placeholderReceiptURL
 	}
 
+	// Stored the way imported plates are, e.g. "wxy 1234" as "WXY1234"
+	carPlate := models.NormalizeCarPlate(warranty.CarPlate)
+
 	query := `
-		INSERT INTO warranties (id, name, phone_number, email, purchase_date, expiry_date, car_plate, receipt)
+		INSERT INTO warranties AS w (id, name, phone_number, email, purchase_date, expiry_date, car_plate, receipt)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, phone_number, email, purchase_date, expiry_date, car_plate, receipt, created_at, updated_at
-	`
+		RETURNING ` + warrantyColumns
 
-	log.Printf("Executing SQL query: %s with params: [%s, %s, %s, %s, %s, %s, %s, %s]",
-		query, warrantyID, warranty.Name, warranty.PhoneNumber, warranty.Email,
-		warranty.PurchaseDate.Format("2006-01-02"), expiryDate.Format("2006-01-02"), warranty.CarPlate, receiptURL)
+	slog.DebugContext(ctx, "creating warranty", "warranty_id", warrantyID, "car_plate", carPlate,
+		"purchase_date", warranty.PurchaseDate.String(), "expiry_date", expiryDate.String())
 
-	row := db.QueryRow(context.Background(), query,
+	result, err := scanWarranty(db.QueryRow(ctx, query,
 		warrantyID,
 		warranty.Name,
 		warranty.PhoneNumber,
 		warranty.Email,
 		warranty.PurchaseDate,
 		expiryDate,
-		warranty.CarPlate,
+		carPlate,
 		receiptURL,
-	)
-
-	var result models.Warranty
-	var purchaseDate, expiryDateDB, createdAt, updatedAt pgtype.Timestamp
-
-	err := row.Scan(
-		&result.ID,
-		&result.Name,
-		&result.PhoneNumber,
-		&result.Email,
-		&purchaseDate,
-		&expiryDateDB,
-		&result.CarPlate,
-		&result.Receipt,
-		&createdAt,
-		&updatedAt,
-	)
-
+	))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create warranty: %v", err)
 	}
 
-	// Convert pgtype.Timestamp to time.Time
-	if purchaseDate.Valid {
-		result.PurchaseDate = purchaseDate.Time
-	}
-	if expiryDateDB.Valid {
-		result.ExpiryDate = expiryDateDB.Time
-	}
-	if createdAt.Valid {
-		result.CreatedAt = createdAt.Time
-	}
-	if updatedAt.Valid {
-		result.UpdatedAt = updatedAt.Time
-	}
-
-	return &result, nil
+	return result, nil
 }
 
 // GetWarrantiesByCarPlate retrieves all warranties for a given car plate
-func GetWarrantiesByCarPlate(carPlate string) ([]models.Warranty, error) {
+func GetWarrantiesByCarPlate(ctx context.Context, carPlate string) ([]models.Warranty, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
 	query := `
-		SELECT id, name, phone_number, email, purchase_date, expiry_date, car_plate, receipt, created_at, updated_at
-		FROM warranties
-		WHERE car_plate = $1
-		ORDER BY created_at DESC
+		SELECT ` + warrantyColumns + `
+		FROM warranties w
+		WHERE ` + normalizedCarPlate + ` = $1
+		ORDER BY w.created_at DESC
 	`
 
-	log.Printf("Executing SQL query: %s with params: [%s]", query, carPlate)
+	slog.DebugContext(ctx, "querying warranties by car plate", "car_plate", carPlate)
 
-	rows, err := db.Query(context.Background(), query, carPlate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query warranties: %v", err)
-	}
-	defer rows.Close()
+	return queryWarranties(ctx, query, models.NormalizeCarPlate(carPlate))
+}
 
-	var warranties []models.Warranty
-	for rows.Next() {
-		var warranty models.Warranty
-		var purchaseDate, expiryDate, createdAt, updatedAt pgtype.Timestamp
-		var email pgtype.Text
-
-		err := rows.Scan(
-			&warranty.ID,
-			&warranty.Name,
-			&warranty.PhoneNumber,
-			&email,
-			&purchaseDate,
-			&expiryDate,
-			&warranty.CarPlate,
-			&warranty.Receipt,
-			&createdAt,
-			&updatedAt,
-		)
+// GetWarrantyByID retrieves a warranty by ID, returning nil if not found
+func GetWarrantyByID(ctx context.Context, warrantyID string) (*models.Warranty, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan warranty: %v", err)
-		}
+	query := `SELECT ` + warrantyColumns + ` FROM warranties w WHERE w.id = $1`
 
-		// Convert pgtype values to Go types
-		if email.Valid {
-			warranty.Email = email.String
-		}
-		if purchaseDate.Valid {
-			warranty.PurchaseDate = purchaseDate.Time
-		}
-		if expiryDate.Valid {
-			warranty.ExpiryDate = expiryDate.Time
-		}
-		if createdAt.Valid {
-			warranty.CreatedAt = createdAt.Time
-		}
-		if updatedAt.Valid {
-			warranty.UpdatedAt = updatedAt.Time
+	warranty, err := scanWarranty(db.QueryRow(ctx, query, warrantyID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
 		}
-
-		warranties = append(warranties, warranty)
+		return nil, fmt.Errorf("failed to get warranty: %v", err)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating warranties: %v", err)
-	}
-
-	return warranties, nil
+	return warranty, nil
 }
 
 // GetValidWarrantyByCarPlate retrieves the active warranty for a given car plate
-func GetValidWarrantyByCarPlate(carPlate string) (*models.Warranty, error) {
+func GetValidWarrantyByCarPlate(ctx context.Context, carPlate string) (*models.Warranty, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
 	query := `
-		SELECT w.id, w.name, w.phone_number, w.email, w.purchase_date, w.expiry_date, w.car_plate, w.receipt, w.created_at, w.updated_at
+		SELECT ` + warrantyColumns + `
 		FROM warranties w
 		LEFT JOIN claims c ON w.id = c.warranty_id
-		WHERE w.car_plate = $1 
-		AND w.expiry_date >= CURRENT_DATE
+		WHERE ` + normalizedCarPlate + ` = $1
+		AND w.expiry_date >= $2 -- today in the business timezone, not the server's
+		AND w.voided_at IS NULL
 		AND c.warranty_id IS NULL  -- Only get warranties not tagged to any claim
 		ORDER BY w.expiry_date DESC
 		LIMIT 1
 	`
 
-	log.Printf("Executing SQL query: %s with params: [%s]", query, carPlate)
-
-	row := db.QueryRow(context.Background(), query, carPlate)
-
-	var warranty models.Warranty
-	var purchaseDate, expiryDate, createdAt, updatedAt pgtype.Timestamp
-	var email pgtype.Text
-
-	err := row.Scan(
-		&warranty.ID,
-		&warranty.Name,
-		&warranty.PhoneNumber,
-		&email,
-		&purchaseDate,
-		&expiryDate,
-		&warranty.CarPlate,
-		&warranty.Receipt,
-		&createdAt,
-		&updatedAt,
-	)
+	slog.DebugContext(ctx, "querying valid warranty by car plate", "car_plate", carPlate)
 
+	warranty, err := scanWarranty(db.QueryRow(ctx, query, models.NormalizeCarPlate(carPlate), models.Today()))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // No valid warranty found
@@ -205,102 +227,33 @@ func GetValidWarrantyByCarPlate(carPlate string) (*models.Warranty, error) {
 		return nil, fmt.Errorf("failed to get valid warranty: %v", err)
 	}
 
-	// Convert pgtype values to Go types
-	if email.Valid {
-		warranty.Email = email.String
-	}
-	if purchaseDate.Valid {
-		warranty.PurchaseDate = purchaseDate.Time
-	}
-	if expiryDate.Valid {
-		warranty.ExpiryDate = expiryDate.Time
-	}
-	if createdAt.Valid {
-		warranty.CreatedAt = createdAt.Time
-	}
-	if updatedAt.Valid {
-		warranty.UpdatedAt = updatedAt.Time
-	}
-
-	return &warranty, nil
+	return warranty, nil
 }
 
 // GetAllValidWarrantiesForCarPlate retrieves all valid warranties for a car plate that can be tagged to a claim
-func GetAllValidWarrantiesForCarPlate(carPlate string) ([]models.Warranty, error) {
+func GetAllValidWarrantiesForCarPlate(ctx context.Context, carPlate string) ([]models.Warranty, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection not initialized")
 	}
 
 	query := `
-		SELECT w.id, w.name, w.phone_number, w.email, w.purchase_date, w.expiry_date, w.car_plate, w.receipt, w.created_at, w.updated_at
+		SELECT ` + warrantyColumns + `
 		FROM warranties w
 		LEFT JOIN claims c ON w.id = c.warranty_id
-		WHERE w.car_plate = $1 
-		AND w.expiry_date >= CURRENT_DATE
+		WHERE ` + normalizedCarPlate + ` = $1
+		AND w.expiry_date >= $2 -- today in the business timezone, not the server's
+		AND w.voided_at IS NULL
 		AND c.warranty_id IS NULL  -- Only get warranties not tagged to any claim
 		ORDER BY w.expiry_date DESC
 	`
 
-	log.Printf("Executing SQL query: %s with params: [%s]", query, carPlate)
-
-	rows, err := db.Query(context.Background(), query, carPlate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query warranties: %v", err)
-	}
-	defer rows.Close()
-
-	var warranties []models.Warranty
-	for rows.Next() {
-		var warranty models.Warranty
-		var purchaseDate, expiryDate, createdAt, updatedAt pgtype.Timestamp
-		var email pgtype.Text
-
-		err := rows.Scan(
-			&warranty.ID,
-			&warranty.Name,
-			&warranty.PhoneNumber,
-			&email,
-			&purchaseDate,
-			&expiryDate,
-			&warranty.CarPlate,
-			&warranty.Receipt,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan warranty: %v", err)
-		}
+	slog.DebugContext(ctx, "querying taggable warranties by car plate", "car_plate", carPlate)
 
-		// Convert pgtype values to Go types
-		if email.Valid {
-			warranty.Email = email.String
-		}
-		if purchaseDate.Valid {
-			warranty.PurchaseDate = purchaseDate.Time
-		}
-		if expiryDate.Valid {
-			warranty.ExpiryDate = expiryDate.Time
-		}
-		if createdAt.Valid {
-			warranty.CreatedAt = createdAt.Time
-		}
-		if updatedAt.Valid {
-			warranty.UpdatedAt = updatedAt.Time
-		}
-
-		warranties = append(warranties, warranty)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating warranties: %v", err)
-	}
-
-	return warranties, nil
+	return queryWarranties(ctx, query, models.NormalizeCarPlate(carPlate), models.Today())
 }
 
 // GetWarrantyReceipt retrieves the receipt URL for a warranty
-func GetWarrantyReceipt(warrantyID string) (string, error) {
+func GetWarrantyReceipt(ctx context.Context, warrantyID string) (string, error) {
 	if db == nil {
 		return "", fmt.Errorf("database connection not initialized")
 	}
@@ -311,9 +264,9 @@ func GetWarrantyReceipt(warrantyID string) (string, error) {
 		WHERE id = $1
 	`
 
-	log.Printf("Executing SQL query: %s with params: [%s]", query, warrantyID)
+	slog.DebugContext(ctx, "querying warranty receipt", "warranty_id", warrantyID)
 
-	row := db.QueryRow(context.Background(), query, warrantyID)
+	row := db.QueryRow(ctx, query, warrantyID)
 
 	var receipt string
 	err := row.Scan(&receipt)
@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrWarrantyVoided is returned when changing a warranty that was voided
+	ErrWarrantyVoided = errors.New("warranty is voided")
+	// ErrWarrantyNotFlagged is returned when clearing a warranty that is not
+	// awaiting review
+	ErrWarrantyNotFlagged = errors.New("warranty is not awaiting review")
+)
+
+const defaultWarrantyPageSize = 20
+
+// likePattern matches value anywhere in a column, treating % and _ in it
+// literally
+func likePattern(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + value + "%"
+}
+
+// phoneSearchDigits reduces a phone search to the digits after any trunk or
+// country prefix, so "012-345 6789" and "+60 12-345 6789" both find a number
+// stored in either local or international form
+func phoneSearchDigits(phone, countryCode string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	switch {
+	case strings.HasPrefix(digits, "00"):
+		digits = strings.TrimPrefix(digits[2:], countryCode)
+	case strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	case strings.HasPrefix(strings.TrimSpace(phone), "+"):
+		digits = strings.TrimPrefix(digits, countryCode)
+	}
+	return digits
+}
+
+// warrantySearchWhere builds the WHERE clause and its arguments for the
+// filters of search
+func warrantySearchWhere(search models.WarrantySearch) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if search.Name != "" {
+		addCondition("w.name ILIKE $%d", likePattern(search.Name))
+	}
+	if search.PhoneNumber != "" {
+		if digits := phoneSearchDigits(search.PhoneNumber, config.AppConfig.PhoneCountryCode); digits != "" {
+			addCondition("regexp_replace(w.phone_number, '[^0-9]', '', 'g') LIKE $%d", likePattern(digits))
+		} else {
+			addCondition("w.phone_number ILIKE $%d", likePattern(search.PhoneNumber))
+		}
+	}
+	if search.CarPlate != "" {
+		addCondition(normalizedCarPlate+" LIKE $%d", likePattern(models.NormalizeCarPlate(search.CarPlate)))
+	}
+	if search.PurchaseFrom != nil {
+		addCondition("w.purchase_date >= $%d::date", *search.PurchaseFrom)
+	}
+	if search.PurchaseTo != nil {
+		// Inclusive of the whole end day
+		addCondition("w.purchase_date < $%d::date + 1", *search.PurchaseTo)
+	}
+	switch search.Status {
+	case "active":
+		addCondition("w.voided_at IS NULL AND w.expiry_date >= $%d", models.Today())
+	case "expired":
+		addCondition("w.voided_at IS NULL AND w.expiry_date < $%d", models.Today())
+	case "voided":
+		conditions = append(conditions, "w.voided_at IS NOT NULL")
+	case "flagged":
+		conditions = append(conditions, "w.voided_at IS NULL AND w.review_status = 'pending'")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	return where, args
+}
+
+// SearchWarranties lists warranties matching search, newest first, one page
+// at a time
+func SearchWarranties(ctx context.Context, search models.WarrantySearch) (*models.WarrantySearchResult, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	if search.Page == 0 {
+		search.Page = 1
+	}
+	if search.PageSize == 0 {
+		search.PageSize = defaultWarrantyPageSize
+	}
+
+	where, args := warrantySearchWhere(search)
+
+	result := &models.WarrantySearchResult{Page: search.Page, PageSize: search.PageSize}
+
+	countQuery := `SELECT COUNT(*) FROM warranties w ` + where
+	if err := db.QueryRow(ctx, countQuery, args...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count warranties: %v", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM warranties w
+		%s
+		ORDER BY w.created_at DESC, w.id
+		LIMIT %d OFFSET %d`,
+		warrantyColumns, where, search.PageSize, (search.Page-1)*search.PageSize)
+
+	warranties, err := queryWarranties(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result.Warranties = warranties
+	if result.Warranties == nil {
+		result.Warranties = []models.Warranty{}
+	}
+
+	return result, nil
+}
+
+// changeWarranty applies change to a warranty and records it in the audit log
+// in one transaction. change edits the warranty in place and returns what it
+// changed; it sees the row locked against concurrent edits. changeWarranty
+// returns nil if the warranty does not exist.
+func changeWarranty(ctx context.Context, warrantyID string, action models.WarrantyAuditAction, changedBy, reason string,
+	change func(warranty *models.Warranty) (map[string]models.FieldChange, error)) (*models.Warranty, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT ` + warrantyColumns + ` FROM warranties w WHERE w.id = $1 FOR UPDATE`
+	warranty, err := scanWarranty(tx.QueryRow(ctx, query, warrantyID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get warranty: %v", err)
+	}
+
+	changes, err := change(warranty)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 && action == models.WarrantyUpdated {
+		return warranty, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE warranties
+		SET name = $2, phone_number = $3, email = NULLIF($4, ''), car_plate = $5,
+		    voided_at = $6, void_reason = NULLIF($7, ''), review_status = NULLIF($8, ''),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, warrantyID, warranty.Name, warranty.PhoneNumber, warranty.Email, warranty.CarPlate,
+		warranty.VoidedAt, warranty.VoidReason, string(warranty.ReviewStatus))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update warranty: %v", err)
+	}
+
+	// The simple query protocol cannot encode a map, so send the JSON text
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode warranty changes: %v", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO warranty_audit_log (warranty_id, action, changed_by, changes, reason)
+		VALUES ($1, $2, NULLIF($3, ''), $4::jsonb, $5)
+	`, warrantyID, string(action), changedBy, string(changesJSON), reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record warranty change: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	slog.InfoContext(ctx, "warranty changed", "warranty_id", warrantyID, "action", action, "changed_by", changedBy)
+	return GetWarrantyByID(ctx, warrantyID)
+}
+
+// setField sets *field to value and records the change if it differs
+func setField(changes map[string]models.FieldChange, name string, field *string, value string) {
+	if *field == value {
+		return
+	}
+	changes[name] = models.FieldChange{From: *field, To: value}
+	*field = value
+}
+
+// UpdateWarranty corrects the details of a warranty that has not been voided
+func UpdateWarranty(ctx context.Context, warrantyID string, req models.UpdateWarrantyRequest, changedBy string) (*models.Warranty, error) {
+	return changeWarranty(ctx, warrantyID, models.WarrantyUpdated, changedBy, req.Reason,
+		func(warranty *models.Warranty) (map[string]models.FieldChange, error) {
+			if warranty.VoidedAt != nil {
+				return nil, ErrWarrantyVoided
+			}
+			changes := map[string]models.FieldChange{}
+			if req.Name != nil {
+				setField(changes, "name", &warranty.Name, *req.Name)
+			}
+			if req.PhoneNumber != nil {
+				setField(changes, "phone_number", &warranty.PhoneNumber, *req.PhoneNumber)
+			}
+			if req.Email != nil {
+				setField(changes, "email", &warranty.Email, *req.Email)
+			}
+			if req.CarPlate != nil {
+				setField(changes, "car_plate", &warranty.CarPlate, models.NormalizeCarPlate(*req.CarPlate))
+			}
+			return changes, nil
+		})
+}
+
+// VoidWarranty marks a warranty as void so it no longer counts as valid
+func VoidWarranty(ctx context.Context, warrantyID, reason, changedBy string) (*models.Warranty, error) {
+	return changeWarranty(ctx, warrantyID, models.WarrantyVoided, changedBy, reason,
+		func(warranty *models.Warranty) (map[string]models.FieldChange, error) {
+			if warranty.VoidedAt != nil {
+				return nil, ErrWarrantyVoided
+			}
+			now := time.Now()
+			warranty.VoidedAt = &now
+			warranty.VoidReason = reason
+			return map[string]models.FieldChange{}, nil
+		})
+}
+
+// TransferWarranty moves a warranty to the customer's new car plate
+func TransferWarranty(ctx context.Context, warrantyID, carPlate, reason, changedBy string) (*models.Warranty, error) {
+	return changeWarranty(ctx, warrantyID, models.WarrantyTransferred, changedBy, reason,
+		func(warranty *models.Warranty) (map[string]models.FieldChange, error) {
+			if warranty.VoidedAt != nil {
+				return nil, ErrWarrantyVoided
+			}
+			changes := map[string]models.FieldChange{}
+			setField(changes, "car_plate", &warranty.CarPlate, models.NormalizeCarPlate(carPlate))
+			return changes, nil
+		})
+}
+
+// ClearWarrantyReview takes a flagged registration out of the review queue
+// after it was found genuine
+func ClearWarrantyReview(ctx context.Context, warrantyID, reason, changedBy string) (*models.Warranty, error) {
+	return changeWarranty(ctx, warrantyID, models.WarrantyCleared, changedBy, reason,
+		func(warranty *models.Warranty) (map[string]models.FieldChange, error) {
+			if warranty.VoidedAt != nil {
+				return nil, ErrWarrantyVoided
+			}
+			if warranty.ReviewStatus != models.ReviewPending {
+				return nil, ErrWarrantyNotFlagged
+			}
+			changes := map[string]models.FieldChange{}
+			status := string(warranty.ReviewStatus)
+			setField(changes, "review_status", &status, string(models.ReviewCleared))
+			warranty.ReviewStatus = models.WarrantyReviewStatus(status)
+			return changes, nil
+		})
+}
+
+// GetWarrantyAuditLog returns the recorded changes of a warranty, oldest first
+func GetWarrantyAuditLog(ctx context.Context, warrantyID string) ([]models.WarrantyAuditEntry, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		SELECT id, warranty_id, action, COALESCE(changed_by, ''), changes, reason, created_at
+		FROM warranty_audit_log
+		WHERE warranty_id = $1
+		ORDER BY created_at ASC`
+
+	rows, err := db.Query(ctx, query, warrantyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query warranty audit log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.WarrantyAuditEntry{}
+	for rows.Next() {
+		var entry models.WarrantyAuditEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.WarrantyID,
+			&entry.Action,
+			&entry.ChangedBy,
+			&entry.Changes,
+			&entry.Reason,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warranty audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating warranty audit log: %v", err)
+	}
+
+	return entries, nil
+}
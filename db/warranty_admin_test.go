@@ -0,0 +1,41 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/models"
+)
+
+func TestPhoneSearchDigits(t *testing.T) {
+	tests := []struct{ search, want string }{
+		{"012-345 6789", "123456789"},
+		{"+60 12-345 6789", "123456789"},
+		{"0060123456789", "123456789"},
+		{"+65 9123 4567", "6591234567"},
+		{"3456", "3456"},
+		{"abc", ""},
+	}
+	for _, tt := range tests {
+		if got := phoneSearchDigits(tt.search, "60"); got != tt.want {
+			t.Errorf("phoneSearchDigits(%q) = %q, want %q", tt.search, got, tt.want)
+		}
+	}
+}
+
+func TestWarrantySearchWhereNormalizesPlateAndPhone(t *testing.T) {
+	countryCode := config.AppConfig.PhoneCountryCode
+	config.AppConfig.PhoneCountryCode = "60"
+	t.Cleanup(func() { config.AppConfig.PhoneCountryCode = countryCode })
+
+	where, args := warrantySearchWhere(models.WarrantySearch{PhoneNumber: "+60 12-345", CarPlate: "wxy 12"})
+
+	if !strings.Contains(where, "regexp_replace(w.phone_number, '[^0-9]', '', 'g') LIKE $1") ||
+		!strings.Contains(where, normalizedCarPlate+" LIKE $2") {
+		t.Errorf("where = %s", where)
+	}
+	if len(args) != 2 || args[0] != "%12345%" || args[1] != "%WXY12%" {
+		t.Errorf("args = %v", args)
+	}
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// importBatchSize is how many rows are sent to the database at a time
+const importBatchSize = 500
+
+// ImportWarranties saves the valid rows of an import and records the run,
+// filling in imp's ID, counts and creation time. Rows imported before (same
+// import key) or matching a warranty that is not voided by plate and purchase
+// date are counted as duplicates and left alone. In a dry run the rows
+// are inserted and rolled back, so the counts are exactly what a real run
+// would do.
+func ImportWarranties(ctx context.Context, imp *models.WarrantyImport, rows []models.ImportedWarranty) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	imp.ID = uuid.New().String()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	imp.ImportedRows = 0
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := min(start+importBatchSize, len(rows))
+
+		batch := &pgx.Batch{}
+		for _, row := range rows[start:end] {
+			// A warranty already registered in the app for the same car and
+			// purchase date is the same registration, whatever the phone
+			batch.Queue(`
+				INSERT INTO warranties (name, phone_number, email, purchase_date, expiry_date, car_plate, receipt, import_id, import_key)
+				SELECT $1, $2, NULLIF($3, ''), $4::date, $5::date, $6, $7, $8::uuid, $9
+				WHERE NOT EXISTS (
+				    SELECT 1 FROM warranties w
+				    WHERE upper(regexp_replace(w.car_plate, '[^[:alnum:]]', '', 'g')) = $6
+				    AND w.purchase_date = $4::date
+				    AND w.voided_at IS NULL
+				)
+				ON CONFLICT (import_key) DO NOTHING
+			`, row.Name, row.PhoneNumber, row.Email, row.PurchaseDate, row.ExpiryDate, row.CarPlate, row.Receipt, imp.ID, row.ImportKey)
+		}
+
+		results := tx.SendBatch(ctx, batch)
+		for range rows[start:end] {
+			tag, err := results.Exec()
+			if err != nil {
+				results.Close()
+				return fmt.Errorf("failed to import warranty: %v", err)
+			}
+			imp.ImportedRows += int(tag.RowsAffected())
+		}
+		if err := results.Close(); err != nil {
+			return fmt.Errorf("failed to import warranties: %v", err)
+		}
+	}
+	imp.DuplicateRows = len(rows) - imp.ImportedRows
+
+	if imp.DryRun {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf("failed to roll back dry run: %v", err)
+		}
+		return insertWarrantyImport(ctx, db, imp)
+	}
+
+	if err := insertWarrantyImport(ctx, tx, imp); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	slog.InfoContext(ctx, "warranties imported", "import_id", imp.ID, "imported", imp.ImportedRows,
+		"duplicates", imp.DuplicateRows, "errors", imp.ErrorRows)
+	return nil
+}
+
+// execer is what insertWarrantyImport needs from a pool or transaction
+type execer interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertWarrantyImport(ctx context.Context, q execer, imp *models.WarrantyImport) error {
+	rowErrors := imp.Errors
+	if rowErrors == nil {
+		rowErrors = []models.ImportRowError{}
+	}
+	errorsJSON, err := json.Marshal(rowErrors)
+	if err != nil {
+		return fmt.Errorf("failed to encode import errors: %v", err)
+	}
+
+	err = q.QueryRow(ctx, `
+		INSERT INTO warranty_imports (id, filename, imported_by, dry_run, total_rows, imported_rows, duplicate_rows, error_rows, errors)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9::jsonb)
+		RETURNING created_at
+	`, imp.ID, imp.Filename, imp.ImportedBy, imp.DryRun, imp.TotalRows, imp.ImportedRows, imp.DuplicateRows,
+		imp.ErrorRows, string(errorsJSON)).Scan(&imp.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record warranty import: %v", err)
+	}
+	return nil
+}
+
+// GetWarrantyImport returns an import run with its row errors, or nil if not
+// found
+func GetWarrantyImport(ctx context.Context, importID string) (*models.WarrantyImport, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	var imp models.WarrantyImport
+	err := db.QueryRow(ctx, `
+		SELECT id, filename, COALESCE(imported_by, ''), dry_run, total_rows, imported_rows, duplicate_rows,
+		       error_rows, errors, created_at
+		FROM warranty_imports
+		WHERE id = $1
+	`, importID).Scan(
+		&imp.ID,
+		&imp.Filename,
+		&imp.ImportedBy,
+		&imp.DryRun,
+		&imp.TotalRows,
+		&imp.ImportedRows,
+		&imp.DuplicateRows,
+		&imp.ErrorRows,
+		&imp.Errors,
+		&imp.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get warranty import: %v", err)
+	}
+	return &imp, nil
+}
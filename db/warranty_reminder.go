@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"tayaria-warranty-be/models"
+)
+
+// GetWarrantiesDueForReminder returns warranties expiring within daysBefore
+// days of today that should get that window's reminder: not voided, no claim
+// made against them and no reminder for this or a later (smaller) window
+// sent yet. A run that was missed therefore sends
+// only the most urgent reminder instead of several at once.
+func GetWarrantiesDueForReminder(ctx context.Context, daysBefore int, today models.Date) ([]models.Warranty, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		SELECT ` + warrantyColumns + `
+		FROM warranties w
+		WHERE w.voided_at IS NULL
+		AND w.expiry_date >= $1 AND w.expiry_date <= $1::date + $2::int
+		AND NOT EXISTS (
+		    -- a claim tagged to the warranty, or one filed for the car since
+		    -- the purchase day began in the business timezone
+		    SELECT 1 FROM claims c
+		    WHERE c.warranty_id = w.id
+		    OR (upper(regexp_replace(c.car_plate, '[^[:alnum:]]', '', 'g')) = ` + normalizedCarPlate + `
+		        AND c.created_at >= (w.purchase_date::timestamp AT TIME ZONE $3))
+		)
+		AND NOT EXISTS (
+		    SELECT 1 FROM warranty_reminders r
+		    WHERE r.warranty_id = w.id AND r.days_before <= $2
+		)
+		ORDER BY w.expiry_date, w.id`
+
+	return queryWarranties(ctx, query, today, daysBefore, models.BusinessTimezone())
+}
+
+// ClaimWarrantyReminder records that the reminder for a window is being sent.
+// It returns false if it was already recorded, e.g. by another instance
+// running the scheduler at the same time, and must not be sent again.
+func ClaimWarrantyReminder(ctx context.Context, warrantyID string, daysBefore int, channel string) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	tag, err := db.Exec(ctx, `
+		INSERT INTO warranty_reminders (warranty_id, days_before, channel)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (warranty_id, days_before) DO NOTHING
+	`, warrantyID, daysBefore, channel)
+	if err != nil {
+		return false, fmt.Errorf("failed to record warranty reminder: %v", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseWarrantyReminder forgets a reminder that could not be sent, so the
+// next run tries again
+func ReleaseWarrantyReminder(ctx context.Context, warrantyID string, daysBefore int) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	_, err := db.Exec(ctx, `DELETE FROM warranty_reminders WHERE warranty_id = $1 AND days_before = $2`, warrantyID, daysBefore)
+	if err != nil {
+		return fmt.Errorf("failed to release warranty reminder: %v", err)
+	}
+	return nil
+}
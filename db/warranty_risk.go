@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AssessWarrantyRisk compares a registration with all others, stores its risk
+// score and queues it for review if the score reaches flagScore. A
+// registration already cleared on review is not queued again. It returns nil
+// if the warranty does not exist.
+func AssessWarrantyRisk(ctx context.Context, warrantyID string, flagScore int) (*models.Warranty, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	// Each count only touches candidate rows found through the receipt,
+	// normalized plate and normalized phone indexes (migration 016); the
+	// expressions must match the index definitions to use them.
+	query := `
+		WITH target AS (
+		    SELECT id, receipt, receipt_hash, purchase_date,
+		           upper(regexp_replace(car_plate, '[^[:alnum:]]', '', 'g')) AS plate,
+		           regexp_replace(phone_number, '[^0-9]', '', 'g') AS phone
+		    FROM warranties
+		    WHERE id = $1
+		)
+		SELECT
+		    (SELECT COUNT(*) FROM warranties o
+		        WHERE o.voided_at IS NULL AND o.id <> t.id
+		        AND ((o.receipt = t.receipt AND t.receipt <> $2) OR o.receipt_hash = t.receipt_hash)),
+		    (SELECT COUNT(*) FROM warranties o
+		        WHERE o.voided_at IS NULL AND o.id <> t.id
+		        AND upper(regexp_replace(o.car_plate, '[^[:alnum:]]', '', 'g')) = t.plate
+		        AND o.purchase_date::date = t.purchase_date::date),
+		    (SELECT COUNT(DISTINCT upper(regexp_replace(o.car_plate, '[^[:alnum:]]', '', 'g'))) FROM warranties o
+		        WHERE o.voided_at IS NULL
+		        AND (regexp_replace(o.phone_number, '[^0-9]', '', 'g') = t.phone OR o.id = t.id))
+		FROM target t`
+
+	var signals models.WarrantyRiskSignals
+	err := db.QueryRow(ctx, query, warrantyID, placeholderReceiptURL).Scan(
+		&signals.SameReceipt,
+		&signals.SamePlateAndDate,
+		&signals.PlatesForPhone,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to assess warranty risk: %v", err)
+	}
+
+	score, reasons := signals.Score()
+
+	// Decided here rather than in SQL: with the simple protocol the
+	// arguments arrive untyped and a comparison would be done as text
+	var reviewStatus *string
+	if score >= flagScore {
+		pending := string(models.ReviewPending)
+		reviewStatus = &pending
+	}
+
+	_, err = db.Exec(ctx, `
+		UPDATE warranties
+		SET risk_score = $2,
+		    risk_reasons = $3,
+		    review_status = COALESCE(review_status, $4)
+		WHERE id = $1
+	`, warrantyID, score, reasons, reviewStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store warranty risk: %v", err)
+	}
+
+	if score >= flagScore {
+		slog.WarnContext(ctx, "warranty registration flagged for review", "warranty_id", warrantyID, "risk_score", score)
+	}
+
+	return GetWarrantyByID(ctx, warrantyID)
+}
+
+// SetWarrantyReceiptHash stores the SHA-256 of a warranty's receipt file
+func SetWarrantyReceiptHash(ctx context.Context, warrantyID, hash string) error {
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	if _, err := db.Exec(ctx, `UPDATE warranties SET receipt_hash = $2 WHERE id = $1`, warrantyID, hash); err != nil {
+		return fmt.Errorf("failed to store receipt hash: %v", err)
+	}
+	return nil
+}
+
+// GetWarrantyReviewQueue returns registrations awaiting review, riskiest
+// first. Voided registrations have been dealt with and are left out.
+func GetWarrantyReviewQueue(ctx context.Context) ([]models.Warranty, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	query := `
+		SELECT ` + warrantyColumns + `
+		FROM warranties w
+		WHERE w.review_status = $1 AND w.voided_at IS NULL
+		ORDER BY w.risk_score DESC, w.created_at ASC`
+
+	warranties, err := queryWarranties(ctx, query, string(models.ReviewPending))
+	if err != nil {
+		return nil, err
+	}
+	if warranties == nil {
+		warranties = []models.Warranty{}
+	}
+	return warranties, nil
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+)
+
+const bom = "\uFEFF"
+
+func writeTable(t *testing.T, format Format, sheet string, rows ...[]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct{ cell, want string }{
+		{"", ""},
+		{"Ali", "Ali"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"+1+cmd|' /C calc'!A0", "'+1+cmd|' /C calc'!A0"},
+		{"-2+3", "'-2+3"},
+		{"\tTAB", "'\tTAB"},
+		{"+", "'+"},
+		{"+60123456789", "+60123456789"},
+		{"+60 12-345 6789", "+60 12-345 6789"},
+		{"+1 (555) 010.0000", "+1 (555) 010.0000"},
+		{"-15", "-15"},
+		{"-1.5", "-1.5"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.cell); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	out := writeTable(t, CSV, "ignored",
+		[]string{"name", "phone", "note"},
+		[]string{"Ali, \"Boss\"", "+60123456789", "=1+1"},
+	)
+
+	if !bytes.HasPrefix(out, []byte(bom)) {
+		t.Fatalf("missing byte order mark: %q", out)
+	}
+	want := "name,phone,note\n\"Ali, \"\"Boss\"\"\",+60123456789,'=1+1\n"
+	if got := string(out[len(bom):]); got != want {
+		t.Errorf("csv = %q, want %q", got, want)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(out[len(bom):])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][0] != "Ali, \"Boss\"" {
+		t.Errorf("records = %q", records)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	out := writeTable(t, XLSX, "Claims & <Co>",
+		[]string{"name", "note"},
+		[]string{"Tan & Sons", "<b>\"hi\"</b>"},
+	)
+
+	z, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatalf("not a zip file: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	if !strings.Contains(files["[Content_Types].xml"], `<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`) {
+		t.Errorf("content types do not declare the worksheet: %s", files["[Content_Types].xml"])
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="Claims &amp; &lt;Co&gt;" sheetId="1" r:id="rId1"/>`) {
+		t.Errorf("sheet name not escaped: %s", files["xl/workbook.xml"])
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	wantRow := `<row><c t="inlineStr"><is><t xml:space="preserve">Tan &amp; Sons</t></is></c>` +
+		`<c t="inlineStr"><is><t xml:space="preserve">&lt;b&gt;&#34;hi&#34;&lt;/b&gt;</t></is></c></row>`
+	if !strings.Contains(sheet, wantRow) {
+		t.Errorf("sheet = %s, want row %s", sheet, wantRow)
+	}
+	if !strings.HasSuffix(sheet, "</sheetData></worksheet>") {
+		t.Errorf("sheet is not closed: %s", sheet)
+	}
+}
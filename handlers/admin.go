@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"tayaria-warranty-be/auth"
 	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/middleware"
 	"tayaria-warranty-be/models"
 	"tayaria-warranty-be/utils"
 	"time"
@@ -12,88 +15,58 @@ import (
 
 // POST /admin/login
 func AdminLogin(c *gin.Context) {
-	var req models.ShopLoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Get shop by username
-	shop, err := db.GetShopByUsername(req.Username)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query shop"})
-		return
-	}
-
-	if shop == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
-	}
-
-	// Check password (plain text comparison)
-	if req.Password != shop.Password {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
-	}
-
-	// Generate JWT token
-	token, err := utils.GenerateToken(shop.ID, shop.Username, string(shop.Role), nil) // nil means no expiry
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// Return token and shop info
-	c.JSON(http.StatusOK, models.ShopLoginResponse{
-		Token: token,
-		Shop:  *shop,
-	})
+	login(c, auth.ShopPortal)
 }
 
 // POST /api/master/login
 func MasterLogin(c *gin.Context) {
+	login(c, auth.MasterPortal)
+}
+
+// login signs a user in through portal and returns the token with the user
+// and their shop
+func login(c *gin.Context, portal auth.Portal) {
 	var req models.ShopLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get shop by username
-	shop, err := db.GetShopByUsername(req.Username)
+	result, err := auth.Login(c.Request.Context(), portal, &req)
 	if err != nil {
+		var locked *auth.LockedError
+		switch {
+		case errors.As(err, &locked):
+			middleware.RespondTooManyRequests(c, time.Until(locked.Until))
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		case errors.Is(err, auth.ErrTwoFactorRequired):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor code required", "two_factor_required": true})
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code", "two_factor_required": true})
+		case errors.Is(err, auth.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		case errors.Is(err, auth.ErrWrongPortal):
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account cannot sign in here"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		}
+		return
+	}
+
+	shop, err := db.GetShopByID(c.Request.Context(), result.User.ShopID)
+	if err != nil || shop == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query shop"})
 		return
 	}
 
-	if shop == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
-	}
-
-	// Verify this is a master account
-	if shop.Role != models.MasterRole {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Master access required"})
-		return
-	}
-
-	// Check password (plain text comparison)
-	if req.Password != shop.Password {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
-	}
-
-	// Generate JWT token with 1-month expiration
-	monthDuration := 30 * 24 * time.Hour
-	token, err := utils.GenerateToken(shop.ID, shop.Username, string(shop.Role), &monthDuration)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// Return token and shop info
 	c.JSON(http.StatusOK, models.ShopLoginResponse{
-		Token: token,
-		Shop:  *shop,
+		Token:     result.Token,
+		ExpiresAt: result.ExpiresAt,
+		Shop:      *shop,
+		User:      *result.User,
+
+		TwoFactorEnrollmentRequired: result.TwoFactorEnrollmentRequired,
 	})
 }
 
@@ -106,19 +79,30 @@ func CreateRetailAccount(c *gin.Context) {
 	}
 
 	// Check if username already exists
-	existingShop, err := db.GetShopByUsername(req.Username)
+	existingShop, err := db.GetShopByUsername(c.Request.Context(), req.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
+		return
+	}
+	existingUser, err := db.GetUserByUsername(c.Request.Context(), req.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
 		return
 	}
 
-	if existingShop != nil {
+	if existingShop != nil || existingUser != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 		return
 	}
 
-	// Create new shop
-	shop, err := db.CreateShop(&req)
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	// Create new shop with its first (manager) user
+	shop, err := db.CreateShop(c.Request.Context(), &req, passwordHash)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create retail account"})
 		return
@@ -141,7 +125,7 @@ func CreateRetailAccount(c *gin.Context) {
 // GET /api/master/account - Get all retail accounts
 func GetRetailAccounts(c *gin.Context) {
 	// Get all shops with admin role (retail accounts)
-	shops, err := db.GetAllShops()
+	shops, err := db.GetAllShops(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch retail accounts"})
 		return
@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"fmt"
-	"log"
 	"net/http"
+	"strings"
 
 	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/metrics"
 	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/notify"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,7 +27,11 @@ func CreateClaim(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Creating claim for shop ID: %v (type: %T)", shopID, shopID)
+	inspections, err := models.ToInspections(req.Inspections)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create a complete request with shop_id from context
 	completeReq := models.CreateClaimRequest{
@@ -37,7 +42,7 @@ func CreateClaim(c *gin.Context) {
 	}
 
 	// Create claim in database (includes warranty validation)
-	claim, err := db.CreateClaim(completeReq, shopID.(string))
+	claim, err := db.CreateClaim(c.Request.Context(), completeReq, shopID.(string), inspections)
 	if err != nil {
 		if err.Error() == "no valid warranty found for car plate "+req.CarPlate {
 			c.JSON(http.StatusNotFound, gin.H{"error": "No valid warranty found for this car plate"})
@@ -47,9 +52,196 @@ func CreateClaim(c *gin.Context) {
 		return
 	}
 
+	metrics.ClaimsCreated.WithLabelValues(claim.ShopID).Inc()
 	c.JSON(http.StatusCreated, claim)
 }
 
+// POST /api/user/claim - Customer starts a claim at their preferred shop
+func SubmitCustomerClaim(c *gin.Context) {
+	var req models.CustomerClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fieldErr := checkNotifyChannel(req.NotifyChannel, req.PhoneNumber, req.Email); fieldErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fieldErr.Field + " " + fieldErr.Message})
+		return
+	}
+
+	shop, err := db.GetShopByID(c.Request.Context(), req.ShopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query shop"})
+		return
+	}
+	if shop == nil || shop.Role != models.AdminRole {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	claim, err := db.CreateCustomerClaim(c.Request.Context(), req)
+	if err != nil {
+		if err.Error() == "no valid warranty found for car plate "+req.CarPlate {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No valid warranty found for this car plate"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit claim"})
+		return
+	}
+
+	metrics.ClaimsCreated.WithLabelValues(claim.ShopID).Inc()
+	saveNotifyChannel(c.Request.Context(), req.PhoneNumber, req.NotifyChannel)
+
+	// Only what the customer needs to follow up; the claim itself stays internal
+	c.JSON(http.StatusCreated, models.CustomerClaimResponse{
+		TrackingRef: claim.TrackingRef,
+		Status:      claim.Status,
+		ShopName:    claim.ShopName,
+		CreatedAt:   claim.CreatedAt,
+	})
+}
+
+// POST /api/user/claim/track - Customer checks a claim's progress by its
+// reference and the phone number it was filed with
+func TrackClaim(c *gin.Context) {
+	var req models.TrackClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claim, err := db.GetClaimByTrackingRef(c.Request.Context(), strings.ToUpper(strings.TrimSpace(req.TrackingRef)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query claim"})
+		return
+	}
+	// Same answer for a wrong reference and a wrong phone number, so
+	// references cannot be probed
+	if claim == nil || !samePhone(claim.PhoneNumber, req.PhoneNumber) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No claim found for this reference and phone number"})
+		return
+	}
+
+	timeline, err := db.GetClaimStatusHistory(c.Request.Context(), claim.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query claim history"})
+		return
+	}
+	if claim.DateClosed != nil {
+		timeline = append(timeline, models.ClaimStatusEvent{Status: models.ClosedStatus, At: *claim.DateClosed})
+	}
+	for i := range timeline {
+		timeline[i].Label = timeline[i].Status.CustomerLabel()
+	}
+
+	status := claim.Status
+	if claim.DateClosed != nil {
+		status = models.ClosedStatus
+	}
+
+	c.JSON(http.StatusOK, models.ClaimTrackingResponse{
+		TrackingRef: claim.TrackingRef,
+		Status:      status,
+		StatusLabel: status.CustomerLabel(),
+		CarPlate:    claim.CarPlate,
+		ShopName:    claim.ShopName,
+		ShopContact: claim.Contact,
+		Timeline:    timeline,
+	})
+}
+
+// samePhone compares phone numbers in E.164 form, so "012-345 6789",
+// "+6012-345 6789" and "60123456789" are the same number. Numbers that
+// cannot be normalized never match.
+func samePhone(a, b string) bool {
+	normalizedA, err := notify.NormalizePhone(a)
+	if err != nil {
+		return false
+	}
+	normalizedB, err := notify.NormalizePhone(b)
+	if err != nil {
+		return false
+	}
+	return normalizedA == normalizedB
+}
+
+// GET /api/user/shops - Shops a customer can choose when starting a claim
+func GetClaimShops(c *gin.Context) {
+	shops, err := db.GetAllShops(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query shops"})
+		return
+	}
+
+	response := make([]models.PublicShop, 0, len(shops))
+	for _, shop := range shops {
+		response = append(response, models.PublicShop{
+			ID:       shop.ID,
+			ShopName: shop.ShopName,
+			Address:  shop.Address,
+			Contact:  shop.Contact,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /api/admin/claim/:id/accept-submission - Take a customer-submitted
+// claim into the normal flow
+func AcceptCustomerClaim(c *gin.Context) {
+	claim, err := db.AcceptCustomerClaim(c.Request.Context(), c.Param("id"), c.GetString("shop_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if claim == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No customer-submitted claim found for this shop"})
+		return
+	}
+
+	notifyClaimUpdate(c, claim)
+	c.JSON(http.StatusOK, claim)
+}
+
+// PUT /api/admin/claim/:id/inspections - Record or correct the inspection of
+// a claim's damaged tyres before it is decided
+func UpdateClaimInspections(c *gin.Context) {
+	var req models.UpdateInspectionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inspections, err := models.ToInspections(req.Inspections)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claimID := c.Param("id")
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if claim == nil || claim.ShopID != c.GetString("shop_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+	if claim.Status == models.ApprovedStatus || claim.Status == models.RejectedStatus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inspections can only be changed before a claim is decided"})
+		return
+	}
+
+	claim.Inspections, err = db.ReplaceClaimInspections(c.Request.Context(), claimID, inspections)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	claim.Eligibility = models.CheckEligibility(claim.Inspections)
+
+	c.JSON(http.StatusOK, claim)
+}
+
 // GET /api/admin/claims
 func GetShopClaims(c *gin.Context) {
 	// Get shop_id from context (set by AdminMiddleware)
@@ -59,7 +251,7 @@ func GetShopClaims(c *gin.Context) {
 		return
 	}
 
-	claims, err := db.GetShopClaims(shopID.(string))
+	claims, err := db.GetShopClaims(c.Request.Context(), shopID.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -79,7 +271,7 @@ func TagWarrantyToClaim(c *gin.Context) {
 	claimID := c.Param("id")
 
 	// Get the claim
-	claim, err := db.GetClaimByID(claimID)
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -95,8 +287,19 @@ func TagWarrantyToClaim(c *gin.Context) {
 		return
 	}
 
+	// Voided and expired warranties cannot back a claim
+	warranty, err := db.GetWarrantyByID(c.Request.Context(), req.WarrantyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if warranty == nil || !warranty.ActiveOn(models.Today()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Warranty not found, voided or expired"})
+		return
+	}
+
 	// Update the claim with the warranty ID
-	updatedClaim, err := db.UpdateClaimWarrantyID(claimID, req.WarrantyID)
+	updatedClaim, err := db.UpdateClaimWarrantyID(c.Request.Context(), claimID, req.WarrantyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -116,7 +319,7 @@ func ChangeClaimStatus(c *gin.Context) {
 	claimID := c.Param("id")
 
 	// Get the current claim
-	claim, err := db.GetClaimByID(claimID)
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -152,12 +355,17 @@ func ChangeClaimStatus(c *gin.Context) {
 	}
 
 	// Update claim status
-	updatedClaim, err := db.UpdateClaimStatus(claimID, req.Status, req.RejectionReason)
+	updatedClaim, err := db.UpdateClaimStatus(c.Request.Context(), claimID, req.Status, req.RejectionReason)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if req.Status == models.ApprovedStatus || req.Status == models.RejectedStatus {
+		metrics.ClaimDecisions.WithLabelValues(string(req.Status)).Inc()
+	}
+
+	notifyClaimUpdate(c, updatedClaim)
 	c.JSON(http.StatusOK, updatedClaim)
 }
 
@@ -165,8 +373,19 @@ func ChangeClaimStatus(c *gin.Context) {
 func CloseClaim(c *gin.Context) {
 	claimID := c.Param("id")
 
+	// Staff can only close their own shop's claims
+	existing, err := db.GetClaimByID(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing == nil || existing.ShopID != c.GetString("shop_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+
 	// Close the claim
-	claim, err := db.CloseClaim(claimID)
+	claim, err := db.CloseClaim(c.Request.Context(), claimID)
 	if err != nil {
 		if err.Error() == "claim not found or not in approved/rejected status" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Can only close approved or rejected claims"})
@@ -191,7 +410,7 @@ func GetAllClaims(c *gin.Context) {
 	}
 
 	// Get claims based on status
-	claims, err := db.GetClaimsByStatus(status)
+	claims, err := db.GetClaimsByStatus(c.Request.Context(), status)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -204,8 +423,8 @@ func GetAllClaims(c *gin.Context) {
 func GetClaimInfoByID(c *gin.Context) {
 	claimID := c.Param("id")
 
-	// Get the claim with tyre details
-	claim, err := db.GetClaimWithTyreDetails(claimID)
+	// Claim, shop, tyre details and tagged warranty in one query
+	claim, warranty, err := db.GetClaimInfo(c.Request.Context(), claimID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -215,22 +434,19 @@ func GetClaimInfoByID(c *gin.Context) {
 		return
 	}
 
-	// If claim has a warranty_id, get the warranty details
-	var warranty *models.Warranty
-	if claim.WarrantyID != nil {
-		warranties, err := db.GetWarrantiesByCarPlate(claim.CarPlate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get warranty details: %v", err)})
-			return
-		}
-		// Find the specific warranty
-		for _, w := range warranties {
-			if w.ID == *claim.WarrantyID {
-				warranty = &w
-				break
-			}
-		}
+	photos, err := db.GetClaimPhotos(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	claim.Photos = withPhotoURLs(c, photos)
+
+	claim.Inspections, err = db.GetClaimInspections(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	claim.Eligibility = models.CheckEligibility(claim.Inspections)
 
 	// Return combined response
 	c.JSON(http.StatusOK, gin.H{
@@ -244,7 +460,7 @@ func ChangeClaimStatusToPending(c *gin.Context) {
 	claimID := c.Param("id")
 
 	// Get the current claim
-	claim, err := db.GetClaimByID(claimID)
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -261,12 +477,13 @@ func ChangeClaimStatusToPending(c *gin.Context) {
 	}
 
 	// Update claim status to pending
-	updatedClaim, err := db.UpdateClaimStatus(claimID, models.PendingStatus, "")
+	updatedClaim, err := db.UpdateClaimStatus(c.Request.Context(), claimID, models.PendingStatus, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	notifyClaimUpdate(c, updatedClaim)
 	c.JSON(http.StatusOK, updatedClaim)
 }
 
@@ -281,7 +498,7 @@ func ChangeClaimStatusToAccepted(c *gin.Context) {
 	claimID := c.Param("id")
 
 	// Get the current claim
-	claim, err := db.GetClaimByID(claimID)
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -308,12 +525,15 @@ func ChangeClaimStatusToAccepted(c *gin.Context) {
 	}
 
 	// Update claim status and add tyre details
-	updatedClaim, err := db.AcceptClaim(claimID, tyreDetails)
+	updatedClaim, err := db.AcceptClaim(c.Request.Context(), claimID, tyreDetails)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	metrics.ClaimDecisions.WithLabelValues(string(models.ApprovedStatus)).Inc()
+
+	notifyClaimUpdate(c, updatedClaim)
 	c.JSON(http.StatusOK, updatedClaim)
 }
 
@@ -328,7 +548,7 @@ func ChangeClaimStatusToRejected(c *gin.Context) {
 	claimID := c.Param("id")
 
 	// Get the current claim
-	claim, err := db.GetClaimByID(claimID)
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -345,11 +565,14 @@ func ChangeClaimStatusToRejected(c *gin.Context) {
 	}
 
 	// Update claim status with rejection reason
-	updatedClaim, err := db.RejectClaim(claimID, req.RejectionReason)
+	updatedClaim, err := db.RejectClaim(c.Request.Context(), claimID, req.RejectionReason)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	metrics.ClaimDecisions.WithLabelValues(string(models.RejectedStatus)).Inc()
+
+	notifyClaimUpdate(c, updatedClaim)
 	c.JSON(http.StatusOK, updatedClaim)
 }
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // register PNG decoding for uploads
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/storage"
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxPhotosPerUpload = 10
+	maxPhotosPerClaim  = 40
+	// maxPhotoPixels rejects images that would take too much memory to
+	// decode, whatever their file size
+	maxPhotoPixels = 50_000_000
+	thumbnailSize  = 320
+)
+
+// errInvalidPhoto means an uploaded file is not a JPEG or PNG image we can use
+var errInvalidPhoto = errors.New("photos must be JPEG or PNG images")
+
+// POST /api/admin/claim/:id/photos - Attach damage photos to a claim.
+// multipart/form-data with one or more "photos" files and an optional
+// "tyre_position" the photos show.
+func UploadClaimPhotos(c *gin.Context) {
+	claimID := c.Param("id")
+
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if claim == nil || claim.ShopID != c.GetString("shop_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+	if claim.Status == models.ApprovedStatus || claim.Status == models.RejectedStatus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Photos can only be added before a claim is decided"})
+		return
+	}
+
+	maxBytes := config.AppConfig.ClaimPhotoMaxBytes
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes*maxPhotosPerUpload+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected multipart/form-data with photos"})
+		return
+	}
+
+	files := form.File["photos"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one photo is required"})
+		return
+	}
+	if len(files) > maxPhotosPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d photos per upload", maxPhotosPerUpload)})
+		return
+	}
+	for _, file := range files {
+		if file.Size > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Photo %s is larger than %d bytes", file.Filename, maxBytes)})
+			return
+		}
+	}
+
+	position := models.TyrePosition(c.PostForm("tyre_position"))
+	if position != "" && !position.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tyre_position. Must be one of: front_left, front_right, rear_left, rear_right, spare"})
+		return
+	}
+
+	count, err := db.CountClaimPhotos(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count+len(files) > maxPhotosPerClaim {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A claim can have at most %d photos", maxPhotosPerClaim)})
+		return
+	}
+
+	photos := make([]models.ClaimPhoto, 0, len(files))
+	for _, file := range files {
+		photo, err := saveClaimPhoto(c.Request.Context(), claimID, position, c.GetString("user_id"), file)
+		if err != nil {
+			if errors.Is(err, errInvalidPhoto) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %v", file.Filename, err)})
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "failed to save claim photo", "claim_id", claimID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo"})
+			return
+		}
+		photos = append(photos, *photo)
+	}
+
+	c.JSON(http.StatusCreated, withPhotoURLs(c, photos))
+}
+
+// GET /api/admin/claim/:id/photos - Photos of one of the shop's claims
+func GetClaimPhotos(c *gin.Context) {
+	claimID := c.Param("id")
+
+	claim, err := db.GetClaimByID(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if claim == nil || claim.ShopID != c.GetString("shop_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+
+	photos, err := db.GetClaimPhotos(c.Request.Context(), claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, withPhotoURLs(c, photos))
+}
+
+// GET /api/admin/claim/:id/photos/:photoId, GET /api/master/claim/:id/photos/:photoId
+// Streams the photo, or its thumbnail with ?size=thumbnail
+func GetClaimPhotoFile(c *gin.Context) {
+	claimID := c.Param("id")
+
+	// Shop staff only see their own shop's photos
+	if !callerRole(c).Can(models.PermReadAllClaims) {
+		claim, err := db.GetClaimByID(c.Request.Context(), claimID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if claim == nil || claim.ShopID != c.GetString("shop_id") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+			return
+		}
+	}
+
+	photo, err := db.GetClaimPhoto(c.Request.Context(), claimID, c.Param("photoId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if photo == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+
+	key, contentType := photo.StorageKey, photo.ContentType
+	if c.Query("size") == "thumbnail" {
+		key, contentType = photo.ThumbnailKey, "image/jpeg"
+	}
+
+	file, err := storage.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(c.Request.Context(), "claim photo missing from storage", "photo_id", photo.ID, "key", key)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read photo"})
+		return
+	}
+	defer file.Close()
+
+	// Photos never change once uploaded
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.DataFromReader(http.StatusOK, -1, contentType, file, nil)
+}
+
+// saveClaimPhoto checks an uploaded image, stores it with a thumbnail and
+// records it against the claim
+func saveClaimPhoto(ctx context.Context, claimID string, position models.TyrePosition, uploadedBy string, file *multipart.FileHeader) (*models.ClaimPhoto, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %v", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %v", err)
+	}
+
+	// Trust the content, not the client's Content-Type or file name
+	contentType := http.DetectContentType(data)
+	var ext string
+	switch contentType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	default:
+		return nil, errInvalidPhoto
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxPhotoPixels {
+		return nil, errInvalidPhoto
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errInvalidPhoto
+	}
+
+	var thumbnail bytes.Buffer
+	if err := jpeg.Encode(&thumbnail, utils.Thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+
+	photoID := uuid.New().String()
+	photo := models.ClaimPhoto{
+		ID:           photoID,
+		ClaimID:      claimID,
+		TyrePosition: position,
+		ContentType:  contentType,
+		SizeBytes:    int64(len(data)),
+		Width:        cfg.Width,
+		Height:       cfg.Height,
+		UploadedBy:   uploadedBy,
+		StorageKey:   fmt.Sprintf("claims/%s/%s%s", claimID, photoID, ext),
+		ThumbnailKey: fmt.Sprintf("claims/%s/%s_thumb.jpg", claimID, photoID),
+	}
+
+	if err := storage.Put(ctx, photo.StorageKey, contentType, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := storage.Put(ctx, photo.ThumbnailKey, "image/jpeg", &thumbnail); err != nil {
+		removeStoredPhoto(ctx, photo)
+		return nil, err
+	}
+
+	created, err := db.CreateClaimPhoto(ctx, photo)
+	if err != nil {
+		removeStoredPhoto(ctx, photo)
+		return nil, err
+	}
+	return created, nil
+}
+
+// removeStoredPhoto deletes the files of a photo that could not be recorded
+func removeStoredPhoto(ctx context.Context, photo models.ClaimPhoto) {
+	for _, key := range []string{photo.StorageKey, photo.ThumbnailKey} {
+		if err := storage.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "failed to remove stored photo", "key", key, "error", err)
+		}
+	}
+}
+
+// withPhotoURLs fills in the URLs the caller can fetch each photo from
+func withPhotoURLs(c *gin.Context, photos []models.ClaimPhoto) []models.ClaimPhoto {
+	prefix := "/api/admin"
+	if callerRole(c).Can(models.PermReadAllClaims) {
+		prefix = "/api/master"
+	}
+	for i := range photos {
+		photos[i].URL = fmt.Sprintf("%s/claim/%s/photos/%s", prefix, photos[i].ClaimID, photos[i].ID)
+		photos[i].ThumbnailURL = photos[i].URL + "?size=thumbnail"
+	}
+	return photos
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/export"
+	"tayaria-warranty-be/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const exportTimeLayout = "2006-01-02 15:04"
+
+var claimExportHeader = []string{
+	"Tracking Ref", "Claim ID", "Status", "Shop", "Shop Contact", "Customer Name", "Phone Number", "Email",
+	"Car Plate", "Warranty ID", "Rejection Reason", "Tyres Replaced", "Tyre Details",
+	"Created At", "Date Settled", "Date Closed",
+}
+
+var warrantyExportHeader = []string{
+	"Warranty ID", "Name", "Phone Number", "Email", "Car Plate", "Purchase Date", "Expiry Date",
+	"Receipt", "Registered At", "Voided At", "Void Reason", "Risk Score", "Review Status",
+}
+
+// GET /api/master/claims/export?status=history&format=csv|xlsx - Download
+// claims with the same status filter as GET /api/master/claims
+func ExportClaims(c *gin.Context) {
+	status := c.Query("status")
+	if status != "unacknowledged" && status != "pending" && status != "history" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status parameter. Must be one of: unacknowledged, pending, history"})
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	streamExport(c, format, "claims-"+status, "Claims", claimExportHeader, func(w export.Writer) error {
+		return db.EachClaimByStatus(c.Request.Context(), status, func(claim models.Claim) error {
+			return w.WriteRow(claimExportRow(claim))
+		})
+	})
+}
+
+// GET /api/master/warranties/export?format=csv|xlsx - Download warranties
+// with the same filters as GET /api/master/warranties, without paging
+func ExportWarranties(c *gin.Context) {
+	search, ok := bindWarrantySearch(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	streamExport(c, format, "warranties", "Warranties", warrantyExportHeader, func(w export.Writer) error {
+		return db.EachWarranty(c.Request.Context(), search, func(warranty models.Warranty) error {
+			return w.WriteRow(warrantyExportRow(warranty))
+		})
+	})
+}
+
+// exportFormat reads the format query parameter, answering 400 if unknown
+func exportFormat(c *gin.Context) (export.Format, bool) {
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format parameter. Must be one of: csv, xlsx"})
+		return "", false
+	}
+	return format, true
+}
+
+// streamExport sends a file download whose rows are written by writeRows as
+// they are read. Once streaming has started the status can no longer change,
+// so a failure part way is logged and the download ends early.
+func streamExport(c *gin.Context, format export.Format, name, sheet string, header []string, writeRows func(w export.Writer) error) {
+	filename := fmt.Sprintf("%s-%s.%s", name, models.Today().String(), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, c.Writer, sheet)
+	if err == nil {
+		err = w.WriteRow(header)
+	}
+	if err == nil {
+		err = writeRows(w)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "export failed", "export", name, "format", format, "error", err)
+		c.Abort()
+	}
+}
+
+func claimExportRow(claim models.Claim) []string {
+	warrantyID := ""
+	if claim.WarrantyID != nil {
+		warrantyID = *claim.WarrantyID
+	}
+
+	tyres := make([]string, len(claim.TyreDetails))
+	for i, tyre := range claim.TyreDetails {
+		tyres[i] = fmt.Sprintf("%s %s %s", tyre.Brand, tyre.Size, tyre.TreadPattern)
+	}
+
+	return []string{
+		claim.TrackingRef,
+		claim.ID,
+		string(claim.Status),
+		claim.ShopName,
+		claim.Contact,
+		claim.CustomerName,
+		claim.PhoneNumber,
+		claim.Email,
+		claim.CarPlate,
+		warrantyID,
+		claim.RejectionReason,
+		strconv.Itoa(len(claim.TyreDetails)),
+		strings.Join(tyres, "; "),
+		formatExportTime(&claim.CreatedAt),
+		formatExportTime(claim.DateSettled),
+		formatExportTime(claim.DateClosed),
+	}
+}
+
+func warrantyExportRow(warranty models.Warranty) []string {
+	return []string{
+		warranty.ID,
+		warranty.Name,
+		warranty.PhoneNumber,
+		warranty.Email,
+		warranty.CarPlate,
+		warranty.PurchaseDate.String(),
+		warranty.ExpiryDate.String(),
+		warranty.Receipt,
+		formatExportTime(&warranty.CreatedAt),
+		formatExportTime(warranty.VoidedAt),
+		warranty.VoidReason,
+		strconv.Itoa(warranty.RiskScore),
+		string(warranty.ReviewStatus),
+	}
+}
+
+// formatExportTime shows a timestamp in the business timezone, blank if unset
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return models.BusinessTime(*t).Format(exportTimeLayout)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	componentOK   = "ok"
+	componentDown = "unavailable"
+)
+
+// componentStatus is all /readyz shows publicly; the reason a component is
+// unavailable is only logged
+type componentStatus struct {
+	Status string `json:"status"`
+}
+
+// GET /healthz - liveness: the process is up and serving requests
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": componentOK,
+		"build":  config.GetBuildInfo(),
+	})
+}
+
+// GET /readyz - readiness: dependencies are reachable and the schema is current
+func Readyz(c *gin.Context) {
+	ctx := c.Request.Context()
+	components := map[string]componentStatus{
+		"database":   checkComponent(ctx, "database", db.Ping),
+		"migrations": checkMigrations(ctx),
+	}
+	if config.AppConfig.ReadinessCheckMailer {
+		components["mailer"] = checkComponent(ctx, "mailer", utils.CheckMailer)
+	}
+
+	status := componentOK
+	httpStatus := http.StatusOK
+	for _, component := range components {
+		if component.Status != componentOK {
+			status = componentDown
+			httpStatus = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(httpStatus, gin.H{
+		"status":     status,
+		"components": components,
+		"build":      config.GetBuildInfo(),
+	})
+}
+
+// checkComponent runs check with the configured readiness timeout, logging
+// why the component is unavailable if it fails
+func checkComponent(parent context.Context, name string, check func(ctx context.Context) error) componentStatus {
+	ctx, cancel := context.WithTimeout(parent, config.AppConfig.ReadinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := check(ctx); err != nil {
+		slog.WarnContext(parent, "readiness check failed", "component", name,
+			"duration_ms", time.Since(start).Milliseconds(), "error", err)
+		return componentStatus{Status: componentDown}
+	}
+	return componentStatus{Status: componentOK}
+}
+
+func checkMigrations(parent context.Context) componentStatus {
+	return checkComponent(parent, "migrations", func(ctx context.Context) error {
+		pending, err := db.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /.well-known/jwks.json - public keys for verifying our tokens
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.JWKS())
+}
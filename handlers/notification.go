@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/notify"
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkNotifyChannel makes sure the customer can be reached on the channel
+// they chose
+func checkNotifyChannel(channel models.NotificationChannel, phone, email string) *models.FieldError {
+	switch {
+	case channel == models.EmailChannel && email == "":
+		return &models.FieldError{Field: "email", Message: "is required to be notified by email"}
+	case channel.IsMessaging():
+		if _, err := notify.NormalizePhone(phone); err != nil {
+			return &models.FieldError{Field: "phone_number", Message: "must be a valid phone number to be notified by " + string(channel)}
+		}
+	}
+	return nil
+}
+
+// saveNotifyChannel remembers the channel a customer chose, if any. Failing
+// to save it only means the default channel is used.
+func saveNotifyChannel(ctx context.Context, phone string, channel models.NotificationChannel) {
+	if channel == "" {
+		return
+	}
+	if err := notify.SetPreference(ctx, phone, channel); err != nil {
+		slog.WarnContext(ctx, "failed to save notification preference", "channel", channel, "error", err)
+	}
+}
+
+// notifyClaimUpdate tells the customer about their claim's new status in the
+// background
+func notifyClaimUpdate(c *gin.Context, claim *models.Claim) {
+	if claim == nil {
+		return
+	}
+	update := *claim
+	ctx := utils.WithRequestID(context.Background(), utils.RequestIDFromContext(c.Request.Context()))
+	utils.RunInBackground("claim update notification", func(bgCtx context.Context) {
+		channel, err := notify.ClaimUpdate(bgCtx, update)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to send claim update", "claim_id", update.ID, "status", update.Status, "channel", channel, "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "claim update sent", "claim_id", update.ID, "status", update.Status, "channel", channel)
+	})
+}
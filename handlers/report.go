@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxReportDays bounds the period of a report
+const maxReportDays = 5 * 366
+
+const defaultRejectionReasons = 10
+
+// reportFilter reads the from and to (YYYY-MM-DD) query parameters, answering
+// 400 if they are invalid. They default to the last 12 months including the
+// current one.
+func reportFilter(c *gin.Context) (models.ReportFilter, bool) {
+	today := models.Today()
+	filter := models.ReportFilter{
+		From: models.Date{Year: today.Year, Month: today.Month, Day: 1}.AddMonths(-11),
+		To:   today,
+	}
+
+	for name, date := range map[string]*models.Date{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := models.ParseDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " parameter. Must be a date like 2026-01-31"})
+			return filter, false
+		}
+		*date = parsed
+	}
+
+	if filter.From.After(filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return filter, false
+	}
+	if filter.To.DaysSince(filter.From) > maxReportDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reports can cover at most 5 years"})
+		return filter, false
+	}
+	return filter, true
+}
+
+// masterReportFilter is reportFilter with an optional shop_id parameter
+func masterReportFilter(c *gin.Context) (models.ReportFilter, bool) {
+	filter, ok := reportFilter(c)
+	if !ok {
+		return filter, false
+	}
+	if shopID := c.Query("shop_id"); shopID != "" {
+		if _, err := uuid.Parse(shopID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop_id parameter"})
+			return filter, false
+		}
+		filter.ShopID = shopID
+	}
+	return filter, true
+}
+
+// GET /api/master/reports/claims-by-shop?from=&to=&shop_id=
+func GetClaimsByShopReport(c *gin.Context) {
+	filter, ok := masterReportFilter(c)
+	if !ok {
+		return
+	}
+
+	counts, err := db.GetClaimsByShopMonth(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": filter.From, "to": filter.To, "shops": counts})
+}
+
+// GET /api/master/reports/claim-outcomes?from=&to=&shop_id=
+func GetClaimOutcomesReport(c *gin.Context) {
+	filter, ok := masterReportFilter(c)
+	if !ok {
+		return
+	}
+
+	outcomes, err := db.GetClaimOutcomes(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": filter.From, "to": filter.To, "outcomes": outcomes})
+}
+
+// GET /api/master/reports/rejection-reasons?from=&to=&shop_id=&limit=
+func GetRejectionReasonsReport(c *gin.Context) {
+	filter, ok := masterReportFilter(c)
+	if !ok {
+		return
+	}
+	limit, ok := reasonLimit(c)
+	if !ok {
+		return
+	}
+
+	reasons, err := db.GetTopRejectionReasons(c.Request.Context(), filter, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": filter.From, "to": filter.To, "reasons": reasons})
+}
+
+// reasonLimit reads the limit query parameter, 1 to 50
+func reasonLimit(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultRejectionReasons, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 50"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// GET /api/master/reports/tyres?from=&to=&shop_id=
+func GetTyresReport(c *gin.Context) {
+	filter, ok := masterReportFilter(c)
+	if !ok {
+		return
+	}
+
+	tyres, err := db.GetTyresReplaced(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": filter.From, "to": filter.To, "tyres": tyres})
+}
+
+// GET /api/master/reports/registrations?from=&to=&interval=day|week|month
+func GetRegistrationsReport(c *gin.Context) {
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	interval := models.ReportInterval(c.DefaultQuery("interval", string(models.MonthlyInterval)))
+	if !interval.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval parameter. Must be one of: day, week, month"})
+		return
+	}
+
+	counts, err := db.GetWarrantyRegistrations(c.Request.Context(), filter, interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": filter.From, "to": filter.To, "interval": interval, "registrations": counts})
+}
+
+// GET /api/admin/dashboard?from=&to= - The caller's shop: claim outcomes and
+// turnaround for the period, top rejection reasons, and decided claims
+// still to be closed
+func GetShopDashboard(c *gin.Context) {
+	shopID := c.GetString("shop_id")
+	if shopID == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Shop ID not found in context"})
+		return
+	}
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	filter.ShopID = shopID
+
+	outcomes, err := db.GetClaimOutcomes(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reasons, err := db.GetTopRejectionReasons(c.Request.Context(), filter, defaultRejectionReasons)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	awaitingClosure, err := db.GetClaimsAwaitingClosure(c.Request.Context(), shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ShopDashboard{
+		From:             filter.From,
+		To:               filter.To,
+		Outcomes:         outcomes,
+		RejectionReasons: reasons,
+		AwaitingClosure:  awaitingClosure,
+	})
+}
+
+// GET /api/master/reports/sla?from=&to=&shop_id= - How claims fared against
+// the SLA target of each status
+func GetSLAReport(c *gin.Context) {
+	filter, ok := masterReportFilter(c)
+	if !ok {
+		return
+	}
+
+	statuses, err := db.GetClaimSLAReport(c.Request.Context(), filter, models.ClaimSLATargets(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": filter.From, "to": filter.To, "statuses": statuses})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"net/mail"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Users with users:manage_all can manage any user. Shop managers only manage
+// shop roles within their own shop.
+
+// GET /api/admin/users, GET /api/master/users?shop_id=
+func GetUsers(c *gin.Context) {
+	shopID := c.Query("shop_id")
+	if !callerRole(c).Can(models.PermManageAllUsers) {
+		shopID = c.GetString("shop_id")
+	}
+
+	users, err := db.GetUsers(c.Request.Context(), shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// POST /api/admin/users, POST /api/master/users
+func CreateUser(c *gin.Context) {
+	var req models.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	if callerRole(c).Can(models.PermManageAllUsers) {
+		if req.ShopID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shop_id is required"})
+			return
+		}
+	} else {
+		if !req.Role.IsShopRole() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Shop managers can only create shop staff and managers"})
+			return
+		}
+		req.ShopID = c.GetString("shop_id")
+	}
+
+	shop, err := db.GetShopByID(c.Request.Context(), req.ShopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query shop"})
+		return
+	}
+	if shop == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	existing, err := db.GetUserByUsername(c.Request.Context(), req.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user, err := db.CreateUser(c.Request.Context(), req, passwordHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// PUT /api/admin/users/:id, PUT /api/master/users/:id
+func UpdateUser(c *gin.Context) {
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.Param("id")
+
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	manageAll := callerRole(c).Can(models.PermManageAllUsers)
+	if user == nil || (!manageAll && user.ShopID != c.GetString("shop_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if req.Role != nil {
+		if !req.Role.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+		if !manageAll && !req.Role.IsShopRole() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Shop managers can only assign shop staff and manager roles"})
+			return
+		}
+	}
+
+	if req.Email != nil && *req.Email != "" {
+		if address, err := mail.ParseAddress(*req.Email); err != nil || address.Address != *req.Email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+			return
+		}
+	}
+
+	// Do not let users lock themselves out
+	if user.ID == c.GetString("user_id") && (req.Role != nil || (req.IsActive != nil && !*req.IsActive)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role or deactivate yourself"})
+		return
+	}
+
+	var passwordHash string
+	if req.Password != nil {
+		passwordHash, err = utils.HashPassword(*req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+	}
+
+	updated, err := db.UpdateUser(c.Request.Context(), userID, req, passwordHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if updated == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// callerRole returns the role of the authenticated caller
+func callerRole(c *gin.Context) models.UserRole {
+	return models.UserRole(c.GetString("role"))
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"tayaria-warranty-be/auth"
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	totpIssuer        = "Tayaria"
+	recoveryCodeCount = 10
+)
+
+// POST /api/auth/2fa/enroll - Start enrollment with a new authenticator secret
+func EnrollTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := db.SetPendingTOTPSecret(c.Request.Context(), user.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(totpIssuer, user.Username, secret),
+	})
+}
+
+// POST /api/auth/2fa/verify - Confirm enrollment with a code from the app
+func VerifyTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	}
+
+	step, valid := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := db.EnableTOTP(c.Request.Context(), user.ID, step, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	response := models.TwoFactorVerifyResponse{RecoveryCodes: codes}
+
+	// Swap an enrollment token for a full one so the user can carry on
+	if c.GetString("token_scope") == utils.ScopeMFAEnrollment {
+		result, err := auth.IssueToken(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		response.Token = result.Token
+		response.ExpiresAt = &result.ExpiresAt
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /api/auth/2fa/recovery-codes - Replace all recovery codes
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := currentUserWithCode(c)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := db.ReplaceRecoveryCodes(c.Request.Context(), user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorVerifyResponse{RecoveryCodes: codes})
+}
+
+// POST /api/auth/2fa/disable - Turn off 2FA (shop accounts only)
+func DisableTwoFactor(c *gin.Context) {
+	if callerRole(c).RequiresTwoFactor() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for this role"})
+		return
+	}
+
+	user, ok := currentUserWithCode(c)
+	if !ok {
+		return
+	}
+
+	if _, err := db.ResetTwoFactor(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// POST /api/master/users/:id/2fa/reset - Remove a user's 2FA, e.g. after a
+// lost phone. Users whose role requires 2FA enroll again at next login.
+func ResetUserTwoFactor(c *gin.Context) {
+	found, err := db.ResetTwoFactor(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// currentUser loads the authenticated caller
+func currentUser(c *gin.Context) (*models.User, bool) {
+	user, err := db.GetUserByID(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query user"})
+		return nil, false
+	}
+	if user == nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+// currentUserWithCode loads the authenticated caller and checks the code in
+// the request against their enabled 2FA
+func currentUserWithCode(c *gin.Context) (*models.User, bool) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return nil, false
+	}
+
+	valid, err := auth.VerifyTOTP(c.Request.Context(), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return nil, false
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return nil, false
+	}
+	return user, true
+}
+
+// newRecoveryCodes returns fresh recovery codes and their bcrypt hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i], err = utils.HashPassword(utils.NormalizeRecoveryCode(code))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return codes, hashes, nil
+}
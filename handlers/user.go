@@ -15,9 +15,10 @@ var mockShop = models.Shop{
 	Address:  "123 Main St",
 	Contact:  "+60123456789",
 	Username: "retailer",
-	Password: "retailerpass",
 }
 
+const mockShopPassword = "retailerpass"
+
 // POST /retailer/login
 func RetailerLogin(c *gin.Context) {
 	type LoginRequest struct {
@@ -29,7 +30,7 @@ func RetailerLogin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if req.Username != mockShop.Username || req.Password != mockShop.Password {
+	if req.Username != mockShop.Username || req.Password != mockShopPassword {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
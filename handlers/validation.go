@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"tayaria-warranty-be/models"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// bindingFieldErrors turns the binding errors of req into per-field errors
+// named as in the JSON request. ok is false when err is not a validation
+// error, e.g. malformed JSON, and the whole request has to be rejected.
+func bindingFieldErrors(req interface{}, err error) (fields []models.FieldError, ok bool) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil, false
+	}
+
+	reqType := reflect.TypeOf(req)
+	for reqType.Kind() == reflect.Ptr {
+		reqType = reqType.Elem()
+	}
+
+	for _, fe := range validationErrs {
+		name := fe.Field()
+		if field, found := reqType.FieldByName(fe.StructField()); found {
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+				name = tag
+			}
+		}
+		fields = append(fields, models.FieldError{Field: name, Message: validationMessage(fe)})
+	}
+	return fields, true
+}
+
+// validationMessage describes a failed binding rule in words
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "uuid":
+		return "must be a valid ID"
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "min":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	}
+	return fmt.Sprintf("failed the %s rule", fe.Tag())
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 
+	"tayaria-warranty-be/config"
 	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/metrics"
 	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/notify"
 	"tayaria-warranty-be/utils"
 
 	"github.com/gin-gonic/gin"
@@ -14,50 +19,103 @@ import (
 // POST /api/user/warranty
 func RegisterWarranty(c *gin.Context) {
 	var req models.CreateWarrantyRequest
+	var fields []models.FieldError
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var ok bool
+		if fields, ok = bindingFieldErrors(&req, err); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Report every problem at once, so the form can highlight all of them
+	window := models.RegistrationWindow{MaxDaysAfterPurchase: config.AppConfig.WarrantyRegistrationWindowDays}
+	if fieldErr := window.Check(req.PurchaseDate, models.Today()); fieldErr != nil {
+		fields = append(fields, *fieldErr)
+	}
+	if fieldErr := checkNotifyChannel(req.NotifyChannel, req.PhoneNumber, req.Email); fieldErr != nil {
+		fields = append(fields, *fieldErr)
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
 		return
 	}
 
 	// Create warranty in database
-	warranty, err := db.CreateWarranty(req)
+	warranty, err := db.CreateWarranty(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Send confirmation email to user (non-blocking)
-	if req.Email != "" {
-		go func() {
-			if err := utils.SendWarrantyConfirmationEmail(*warranty); err != nil {
-				log.Printf("Failed to send warranty confirmation email: %v", err)
-			} else {
-				log.Printf("Warranty confirmation email sent successfully to %s", req.Email)
-			}
-		}()
+	metrics.WarrantiesRegistered.Inc()
+
+	// Flag likely duplicates for headquarters review. Registration goes
+	// ahead either way; a failed check is logged, not shown to the customer.
+	if _, err := db.AssessWarrantyRisk(c.Request.Context(), warranty.ID, config.AppConfig.WarrantyReviewRiskScore); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to assess warranty risk", "warranty_id", warranty.ID, "error", err)
 	}
+	checkReceiptContent(c.Request.Context(), warranty.ID, warranty.Receipt)
 
-	c.JSON(http.StatusCreated, warranty)
+	saveNotifyChannel(c.Request.Context(), req.PhoneNumber, req.NotifyChannel)
+
+	// Send confirmation to the customer (non-blocking)
+	ctx := utils.WithRequestID(context.Background(), utils.RequestIDFromContext(c.Request.Context()))
+	utils.RunInBackground("warranty confirmation", func(bgCtx context.Context) {
+		channel, err := notify.WarrantyConfirmation(bgCtx, *warranty)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to send warranty confirmation", "warranty_id", warranty.ID, "channel", channel, "error", err)
+		} else {
+			slog.InfoContext(ctx, "warranty confirmation sent", "warranty_id", warranty.ID, "channel", channel)
+		}
+	})
+
+	c.JSON(http.StatusCreated, warranty.Public())
+}
+
+// checkReceiptContent hashes the receipt file in the background and assesses
+// the registration again, catching the same receipt uploaded under a new link
+func checkReceiptContent(requestCtx context.Context, warrantyID, receiptURL string) {
+	ctx := utils.WithRequestID(context.Background(), utils.RequestIDFromContext(requestCtx))
+	utils.RunInBackground("receipt hash", func(bgCtx context.Context) {
+		hash, err := utils.HashReceipt(bgCtx, receiptURL, config.SupabaseHost())
+		if err != nil {
+			slog.InfoContext(ctx, "receipt content not checked", "warranty_id", warrantyID, "error", err)
+			return
+		}
+		if err := db.SetWarrantyReceiptHash(bgCtx, warrantyID, hash); err != nil {
+			slog.ErrorContext(ctx, "failed to store receipt hash", "warranty_id", warrantyID, "error", err)
+			return
+		}
+		if _, err := db.AssessWarrantyRisk(bgCtx, warrantyID, config.AppConfig.WarrantyReviewRiskScore); err != nil {
+			slog.ErrorContext(ctx, "failed to assess warranty risk", "warranty_id", warrantyID, "error", err)
+		}
+	})
 }
 
 // GET /api/user/warranties/car-plate/:carPlate
 func GetWarrantiesByCarPlate(c *gin.Context) {
 	carPlate := c.Param("carPlate")
 
-	warranties, err := db.GetWarrantiesByCarPlate(carPlate)
+	warranties, err := db.GetWarrantiesByCarPlate(c.Request.Context(), carPlate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, warranties)
+	var response []models.PublicWarranty
+	for _, warranty := range warranties {
+		response = append(response, warranty.Public())
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GET /api/user/warranties/valid/:carPlate
 func HasValidWarrantyByCarPlate(c *gin.Context) {
 	carPlate := c.Param("carPlate")
 
-	warranty, err := db.GetValidWarrantyByCarPlate(carPlate)
+	warranty, err := db.GetValidWarrantyByCarPlate(c.Request.Context(), carPlate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -68,14 +126,14 @@ func HasValidWarrantyByCarPlate(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"valid": true, "warranty": warranty})
+	c.JSON(http.StatusOK, gin.H{"valid": true, "warranty": warranty.Public()})
 }
 
 // GET /api/user/warranty/receipt/:id
 func GetWarrantyReceipt(c *gin.Context) {
 	warrantyID := c.Param("id")
 
-	receiptURL, err := db.GetWarrantyReceipt(warrantyID)
+	receiptURL, err := db.GetWarrantyReceipt(c.Request.Context(), warrantyID)
 	if err != nil {
 		if err.Error() == "warranty not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Warranty not found"})
@@ -88,11 +146,26 @@ func GetWarrantyReceipt(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"receipt_url": receiptURL})
 }
 
+// GET /api/master/warranty/:id
+func GetWarrantyByID(c *gin.Context) {
+	warranty, err := db.GetWarrantyByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if warranty == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warranty not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, warranty)
+}
+
 // GET /api/master/warranties/valid/:carPlate
 func GetValidWarrantiesForTagging(c *gin.Context) {
 	carPlate := c.Param("carPlate")
 
-	warranties, err := db.GetAllValidWarrantiesForCarPlate(carPlate)
+	warranties, err := db.GetAllValidWarrantiesForCarPlate(c.Request.Context(), carPlate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -110,3 +183,147 @@ func GetValidWarrantiesForTagging(c *gin.Context) {
 		"warranties": warranties,
 	})
 }
+
+// bindWarrantySearch reads the warranty filters from the query string,
+// answering 400 if one is invalid
+func bindWarrantySearch(c *gin.Context) (models.WarrantySearch, bool) {
+	var search models.WarrantySearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return search, false
+	}
+
+	for name, date := range map[string]**models.Date{"purchase_from": &search.PurchaseFrom, "purchase_to": &search.PurchaseTo} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := models.ParseDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " parameter. Must be a date like 2026-01-31"})
+			return search, false
+		}
+		*date = &parsed
+	}
+	return search, true
+}
+
+// GET /api/master/warranties?name=&phone_number=&car_plate=&purchase_from=&purchase_to=&status=&page=&page_size=
+func SearchWarranties(c *gin.Context) {
+	search, ok := bindWarrantySearch(c)
+	if !ok {
+		return
+	}
+
+	result, err := db.SearchWarranties(c.Request.Context(), search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PUT /api/master/warranty/:id - Correct typos in a registration
+func UpdateWarranty(c *gin.Context) {
+	var req models.UpdateWarrantyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warranty, err := db.UpdateWarranty(c.Request.Context(), c.Param("id"), req, c.GetString("user_id"))
+	respondWarrantyChange(c, warranty, err)
+}
+
+// POST /api/master/warranty/:id/void - Void a fraudulent registration
+func VoidWarranty(c *gin.Context) {
+	var req models.VoidWarrantyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warranty, err := db.VoidWarranty(c.Request.Context(), c.Param("id"), req.Reason, c.GetString("user_id"))
+	respondWarrantyChange(c, warranty, err)
+}
+
+// POST /api/master/warranty/:id/transfer - Move a warranty to the customer's
+// new car plate
+func TransferWarranty(c *gin.Context) {
+	var req models.TransferWarrantyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warranty, err := db.TransferWarranty(c.Request.Context(), c.Param("id"), req.CarPlate, req.Reason, c.GetString("user_id"))
+	respondWarrantyChange(c, warranty, err)
+}
+
+func respondWarrantyChange(c *gin.Context, warranty *models.Warranty, err error) {
+	if err != nil {
+		if errors.Is(err, db.ErrWarrantyVoided) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Warranty is voided"})
+			return
+		}
+		if errors.Is(err, db.ErrWarrantyNotFlagged) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Warranty is not awaiting review"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if warranty == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warranty not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, warranty)
+}
+
+// GET /api/master/warranty/:id/audit
+func GetWarrantyAuditLog(c *gin.Context) {
+	warranty, err := db.GetWarrantyByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if warranty == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warranty not found"})
+		return
+	}
+
+	entries, err := db.GetWarrantyAuditLog(c.Request.Context(), warranty.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GET /api/master/warranties/review-queue - Registrations flagged as likely
+// duplicates, riskiest first
+func GetWarrantyReviewQueue(c *gin.Context) {
+	warranties, err := db.GetWarrantyReviewQueue(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, warranties)
+}
+
+// POST /api/master/warranty/:id/clear-review - A flagged registration was
+// checked and is genuine. Fraudulent ones are voided instead.
+func ClearWarrantyReview(c *gin.Context) {
+	var req models.ClearWarrantyReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warranty, err := db.ClearWarrantyReview(c.Request.Context(), c.Param("id"), req.Reason, c.GetString("user_id"))
+	respondWarrantyChange(c, warranty, err)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/export"
+	"tayaria-warranty-be/importer"
+	"tayaria-warranty-be/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxImportFileBytes is the largest warranty import file accepted
+const maxImportFileBytes = 20 << 20
+
+var importErrorReportHeader = []string{"Row", "Field", "Value", "Message"}
+
+// POST /api/master/warranties/import - Bulk import historical warranties from
+// a CSV "file". With dry_run=true the file is only checked and counted.
+func ImportWarranties(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected multipart/form-data with a CSV file"})
+		return
+	}
+	if fileHeader.Size > maxImportFileBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d bytes", maxImportFileBytes)})
+		return
+	}
+
+	dryRun := false
+	if value := c.PostForm("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run. Must be true or false"})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	imp, err := importer.ImportWarranties(c.Request.Context(), fileHeader.Filename, file, dryRun, c.GetString("user_id"))
+	if err != nil {
+		if errors.Is(err, importer.ErrInvalidFile) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if imp.ErrorRows > 0 {
+		imp.ErrorReportURL = "/api/master/warranties/imports/" + imp.ID + "/errors"
+	}
+	slog.InfoContext(c.Request.Context(), "warranty import finished", "import_id", imp.ID, "dry_run", imp.DryRun,
+		"total", imp.TotalRows, "imported", imp.ImportedRows, "duplicates", imp.DuplicateRows, "errors", imp.ErrorRows)
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// GET /api/master/warranties/imports/:id/errors?format=csv|xlsx - Download
+// the rows of an import that failed validation and why
+func GetWarrantyImportErrors(c *gin.Context) {
+	importID := c.Param("id")
+	if _, err := uuid.Parse(importID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	imp, err := db.GetWarrantyImport(c.Request.Context(), importID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if imp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	streamExport(c, format, "warranty-import-errors", "Errors", importErrorReportHeader, func(w export.Writer) error {
+		for _, rowErr := range imp.Errors {
+			if err := w.WriteRow(importErrorReportRow(rowErr)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func importErrorReportRow(rowErr models.ImportRowError) []string {
+	return []string{strconv.Itoa(rowErr.Row), rowErr.Field, rowErr.Value, rowErr.Message}
+}
@@ -0,0 +1,266 @@
+// Package importer loads warranties registered before this system from CSV
+// files.
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+
+	"tayaria-warranty-be/config"
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+)
+
+// ErrInvalidFile is returned, wrapped with the reason, for a file that
+// cannot be imported at all, as opposed to one with some invalid rows
+var ErrInvalidFile = errors.New("invalid import file")
+
+// MaxRows bounds the size of one import file
+const MaxRows = 50000
+
+// Column names accepted in the header row, ignoring case, spaces and
+// underscores. Email, receipt and expiry date are optional.
+var columnAliases = map[string]string{
+	"name":           "name",
+	"customername":   "name",
+	"phone":          "phone_number",
+	"phonenumber":    "phone_number",
+	"email":          "email",
+	"carplate":       "car_plate",
+	"plate":          "car_plate",
+	"platenumber":    "car_plate",
+	"vehicleplate":   "car_plate",
+	"purchasedate":   "purchase_date",
+	"dateofpurchase": "purchase_date",
+	"expirydate":     "expiry_date",
+	"receipt":        "receipt",
+	"receipturl":     "receipt",
+}
+
+var requiredColumns = []string{"name", "phone_number", "car_plate", "purchase_date"}
+
+// dateLayouts are the date formats accepted, ISO and the day-first formats
+// used in Malaysian spreadsheets
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "2/1/2006", "02-01-2006", "2-1-2006"}
+
+// column limits from the warranties table
+const (
+	maxNameLength    = 100
+	maxEmailLength   = 100
+	maxPlateLength   = 20
+	maxReceiptLength = 500
+)
+
+// ImportWarranties reads a CSV file of warranties, validates every row and
+// saves the valid ones (or, in a dry run, only counts them). The run is
+// recorded with its row errors whatever the outcome. An error is returned
+// only when the file cannot be read at all or the database fails.
+func ImportWarranties(ctx context.Context, filename string, r io.Reader, dryRun bool, importedBy string) (*models.WarrantyImport, error) {
+	rows, rowErrors, total, err := ParseWarranties(r, models.Today(), config.AppConfig.PhoneCountryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &models.WarrantyImport{
+		Filename:   filename,
+		ImportedBy: importedBy,
+		DryRun:     dryRun,
+		TotalRows:  total,
+		Errors:     rowErrors,
+	}
+	failed := map[int]bool{}
+	for _, rowErr := range rowErrors {
+		failed[rowErr.Row] = true
+	}
+	imp.ErrorRows = len(failed)
+
+	if err := db.ImportWarranties(ctx, imp, rows); err != nil {
+		return nil, err
+	}
+	return imp, nil
+}
+
+// ParseWarranties reads and validates a CSV file of warranties. It returns
+// the valid rows, normalized, every problem found in the others, and the
+// number of data rows read. Local phone numbers get countryCode.
+func ParseWarranties(r io.Reader, today models.Date, countryCode string) ([]models.ImportedWarranty, []models.ImportRowError, int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, 0, fmt.Errorf("%w: the file is empty", ErrInvalidFile)
+		}
+		return nil, nil, 0, fmt.Errorf("%w: failed to read header: %v", ErrInvalidFile, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		key := strings.NewReplacer(" ", "", "_", "", "\uFEFF", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if column, ok := columnAliases[key]; ok {
+			columns[column] = i
+		}
+	}
+	var missing []string
+	for _, column := range requiredColumns {
+		if _, ok := columns[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, 0, fmt.Errorf("%w: missing required columns: %s", ErrInvalidFile, strings.Join(missing, ", "))
+	}
+
+	var rows []models.ImportedWarranty
+	var rowErrors []models.ImportRowError
+	total := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// Rows are numbered as the spreadsheet shows them, the header being 1
+		var parseErr *csv.ParseError
+		line := 0
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		} else if err == nil {
+			line, _ = reader.FieldPos(0)
+		}
+		if err == nil && strings.Join(record, "") == "" {
+			continue // blank lines are not rows
+		}
+
+		total++
+		if total > MaxRows {
+			return nil, nil, 0, fmt.Errorf("%w: more than %d rows; split it into smaller files", ErrInvalidFile, MaxRows)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: line, Message: fmt.Sprintf("unreadable row: %v", err)})
+			continue
+		}
+
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row, errs := parseRow(line, value, today, countryCode)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, total, nil
+}
+
+// parseRow validates and normalizes one row, returning every problem with it
+func parseRow(n int, value func(column string) string, today models.Date, countryCode string) (models.ImportedWarranty, []models.ImportRowError) {
+	var errs []models.ImportRowError
+	fail := func(field, message string) {
+		errs = append(errs, models.ImportRowError{Row: n, Field: field, Value: value(field), Message: message})
+	}
+
+	row := models.ImportedWarranty{
+		Row:     n,
+		Name:    value("name"),
+		Email:   strings.ToLower(value("email")),
+		Receipt: value("receipt"),
+	}
+
+	switch {
+	case row.Name == "":
+		fail("name", "is required")
+	case len(row.Name) > maxNameLength:
+		fail("name", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+
+	if value("phone_number") == "" {
+		fail("phone_number", "is required")
+	} else if phone, err := utils.NormalizePhone(value("phone_number"), countryCode); err != nil {
+		fail("phone_number", "is not a valid phone number")
+	} else {
+		row.PhoneNumber = phone
+	}
+
+	if row.Email != "" {
+		if address, err := mail.ParseAddress(row.Email); err != nil || address.Address != row.Email || len(row.Email) > maxEmailLength {
+			fail("email", "is not a valid email address")
+		}
+	}
+
+	row.CarPlate = models.NormalizeCarPlate(value("car_plate"))
+	switch {
+	case row.CarPlate == "":
+		fail("car_plate", "is required")
+	case len(row.CarPlate) > maxPlateLength:
+		fail("car_plate", fmt.Sprintf("must be at most %d characters", maxPlateLength))
+	}
+
+	purchaseDate, err := parseDate(value("purchase_date"))
+	switch {
+	case value("purchase_date") == "":
+		fail("purchase_date", "is required")
+	case err != nil:
+		fail("purchase_date", "must be a date like 2025-01-31 or 31/01/2025")
+	case purchaseDate.After(today):
+		fail("purchase_date", "cannot be in the future")
+	default:
+		row.PurchaseDate = purchaseDate
+		row.ExpiryDate = purchaseDate.AddMonths(models.WarrantyMonths)
+	}
+
+	if value("expiry_date") != "" {
+		expiryDate, err := parseDate(value("expiry_date"))
+		switch {
+		case err != nil:
+			fail("expiry_date", "must be a date like 2025-07-31 or 31/07/2025")
+		case !row.PurchaseDate.IsZero() && expiryDate.Before(row.PurchaseDate):
+			fail("expiry_date", "cannot be before the purchase date")
+		default:
+			row.ExpiryDate = expiryDate
+		}
+	}
+
+	if len(row.Receipt) > maxReceiptLength {
+		fail("receipt", fmt.Sprintf("must be at most %d characters", maxReceiptLength))
+	}
+	if row.Receipt == "" {
+		// Historical registrations often have no digital receipt
+		row.Receipt = "imported: no receipt"
+	}
+
+	row.ImportKey = importKey(row)
+	return row, errs
+}
+
+func parseDate(s string) (models.Date, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return models.DateOf(t), nil
+		}
+	}
+	return models.Date{}, fmt.Errorf("invalid date %q", s)
+}
+
+// importKey identifies a warranty across imports: the same car, purchase
+// date and phone number is the same registration
+func importKey(row models.ImportedWarranty) string {
+	sum := sha256.Sum256([]byte(row.CarPlate + "|" + row.PurchaseDate.String() + "|" + row.PhoneNumber))
+	return hex.EncodeToString(sum[:])
+}
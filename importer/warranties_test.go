@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"tayaria-warranty-be/models"
+)
+
+var today = models.Date{Year: 2025, Month: 6, Day: 30}
+
+func parse(t *testing.T, csv string) ([]models.ImportedWarranty, []models.ImportRowError, int) {
+	t.Helper()
+	rows, rowErrors, total, err := ParseWarranties(strings.NewReader(csv), today, "60")
+	if err != nil {
+		t.Fatalf("ParseWarranties: %v", err)
+	}
+	return rows, rowErrors, total
+}
+
+func TestParseWarrantiesHeaderAliases(t *testing.T) {
+	headers := []string{
+		"name,phone_number,car_plate,purchase_date",
+		"Customer Name,Phone,Plate,Date of Purchase",
+		"\uFEFFNAME , Phone Number, Vehicle Plate ,Purchase_Date",
+		"customer_name,phonenumber,plate number,purchasedate,Receipt URL,Expiry Date,Email",
+	}
+	for _, header := range headers {
+		t.Run(header, func(t *testing.T) {
+			rows, rowErrors, total := parse(t, header+"\nAli,0123456789,WXY 1234,2025-01-15\n")
+			if len(rowErrors) > 0 || total != 1 || len(rows) != 1 {
+				t.Fatalf("rows=%d errors=%v total=%d", len(rows), rowErrors, total)
+			}
+			if rows[0].Name != "Ali" || rows[0].CarPlate != "WXY1234" {
+				t.Errorf("row = %+v", rows[0])
+			}
+		})
+	}
+}
+
+func TestParseWarrantiesMissingColumns(t *testing.T) {
+	_, _, _, err := ParseWarranties(strings.NewReader("name,phone,email\nAli,0123456789,a@b.com\n"), today, "60")
+	if !errors.Is(err, ErrInvalidFile) || !strings.Contains(err.Error(), "car_plate, purchase_date") {
+		t.Fatalf("err = %v, want ErrInvalidFile naming car_plate and purchase_date", err)
+	}
+
+	if _, _, _, err := ParseWarranties(strings.NewReader(""), today, "60"); !errors.Is(err, ErrInvalidFile) {
+		t.Fatalf("empty file: err = %v, want ErrInvalidFile", err)
+	}
+}
+
+func TestParseWarrantiesNormalizesRows(t *testing.T) {
+	rows, rowErrors, _ := parse(t, `name,phone,plate,purchase date,expiry date,email,receipt
+ Siti ,+60 12-345 6789,wxy-1234,31/01/2025,,Siti@Example.com,https://example.com/r.pdf
+Ah Kow,012 345 6789,"abc 12",1/2/2025,31/12/2025,,
+Raju,0060123456789,Jkl.9,28-02-2025,,,
+`)
+	if len(rowErrors) > 0 {
+		t.Fatalf("unexpected errors: %v", rowErrors)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	first := rows[0]
+	if first.Row != 2 || first.Name != "Siti" || first.PhoneNumber != "+60123456789" ||
+		first.CarPlate != "WXY1234" || first.Email != "siti@example.com" || first.Receipt != "https://example.com/r.pdf" {
+		t.Errorf("first row = %+v", first)
+	}
+	// Day-first dates; expiry defaults to WarrantyMonths after purchase,
+	// clamped to the end of a shorter month
+	if want := (models.Date{Year: 2025, Month: 1, Day: 31}); first.PurchaseDate != want {
+		t.Errorf("purchase date = %v, want %v", first.PurchaseDate, want)
+	}
+	if want := (models.Date{Year: 2025, Month: 7, Day: 31}); first.ExpiryDate != want {
+		t.Errorf("expiry date = %v, want %v", first.ExpiryDate, want)
+	}
+
+	second := rows[1]
+	if second.CarPlate != "ABC12" || second.PhoneNumber != "+60123456789" || second.Receipt != "imported: no receipt" {
+		t.Errorf("second row = %+v", second)
+	}
+	if want := (models.Date{Year: 2025, Month: 2, Day: 1}); second.PurchaseDate != want {
+		t.Errorf("purchase date = %v, want %v (1/2 is 1 February)", second.PurchaseDate, want)
+	}
+	if want := (models.Date{Year: 2025, Month: 12, Day: 31}); second.ExpiryDate != want {
+		t.Errorf("expiry date = %v, want %v from the file", second.ExpiryDate, want)
+	}
+
+	third := rows[2]
+	if third.CarPlate != "JKL9" || third.PurchaseDate != (models.Date{Year: 2025, Month: 2, Day: 28}) {
+		t.Errorf("third row = %+v", third)
+	}
+	if want := (models.Date{Year: 2025, Month: 8, Day: 28}); third.ExpiryDate != want {
+		t.Errorf("expiry date = %v, want %v", third.ExpiryDate, want)
+	}
+
+	// The same car, date and phone written differently is the same warranty
+	if first.ImportKey == "" || first.ImportKey == second.ImportKey {
+		t.Errorf("import keys: %q, %q", first.ImportKey, second.ImportKey)
+	}
+	again, _, _ := parse(t, "name,phone,plate,purchase date\nSiti Aminah,0123456789,WXY 1234,2025-01-31\n")
+	if again[0].ImportKey != first.ImportKey {
+		t.Error("import key differs for the same plate, purchase date and phone")
+	}
+}
+
+func TestParseWarrantiesRowErrors(t *testing.T) {
+	rows, rowErrors, total := parse(t, `name,phone,plate,purchase date,expiry date,email
+Valid,0123456789,AAA1,2025-01-01,,
+
+,0123456789,AAA2,2025-01-01,,
+Bad Phone,12,AAA3,2025-01-01,,
+Bad Date,0123456789,AAA4,01/13/2025,,
+Future,0123456789,AAA5,2025-07-01,,
+Bad Expiry,0123456789,AAA6,2025-03-01,2025-02-01,
+Bad Email,0123456789,AAA7,2025-01-01,,not-an-email
+Missing Fields,,,,,
+`)
+	if total != 8 {
+		t.Errorf("total = %d, want 8 (blank lines are not rows)", total)
+	}
+	if len(rows) != 1 || rows[0].CarPlate != "AAA1" {
+		t.Fatalf("valid rows = %+v, want only AAA1", rows)
+	}
+
+	got := map[string]bool{}
+	for _, e := range rowErrors {
+		got[fmt.Sprintf("%d:%s", e.Row, e.Field)] = true
+	}
+	// Rows are numbered as in a spreadsheet: header 1, blank line 3
+	want := []string{
+		"4:name",
+		"5:phone_number",
+		"6:purchase_date",
+		"7:purchase_date",
+		"8:expiry_date",
+		"9:email",
+		"10:phone_number", "10:car_plate", "10:purchase_date",
+	}
+	for _, key := range want {
+		if !got[key] {
+			t.Errorf("missing error %s", key)
+		}
+	}
+	if len(rowErrors) != len(want) {
+		t.Errorf("got %d errors, want %d: %+v", len(rowErrors), len(want), rowErrors)
+	}
+}
+
+func TestParseWarrantiesUnreadableRow(t *testing.T) {
+	rows, rowErrors, total := parse(t, "name,phone,plate,purchase date\nA \"quoted\" name,0123456789,AAA1,2025-01-01\nOK,0123456789,AAA2,2025-01-01\n")
+	if total != 2 || len(rows) != 1 || len(rowErrors) != 1 || rowErrors[0].Row != 2 {
+		t.Fatalf("rows=%+v errors=%+v total=%d", rows, rowErrors, total)
+	}
+}
+
+func TestParseWarrantiesMaxRows(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("name,phone,plate,purchase date\n")
+	for i := 0; i < MaxRows; i++ {
+		fmt.Fprintf(&b, "Name,0123456789,P%d,2025-01-01\n", i)
+	}
+
+	rows, _, total := parse(t, b.String())
+	if total != MaxRows || len(rows) != MaxRows {
+		t.Fatalf("total=%d rows=%d, want %d", total, len(rows), MaxRows)
+	}
+
+	b.WriteString("One Too Many,0123456789,P,2025-01-01\n")
+	_, _, _, err := ParseWarranties(strings.NewReader(b.String()), today, "60")
+	if !errors.Is(err, ErrInvalidFile) {
+		t.Fatalf("err = %v, want ErrInvalidFile", err)
+	}
+}
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/metrics"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+)
+
+// EscalateOverdueClaims emails headquarters staff who review claims about
+// the claims newly past their SLA target, and returns how many were
+// escalated. Each claim is escalated once each time it enters a status; if
+// the email fails, the next run tries again.
+func EscalateOverdueClaims(ctx context.Context) (int, error) {
+	claims, err := db.GetOverdueClaims(ctx, models.ClaimSLATargets(), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if len(claims) == 0 {
+		return 0, nil
+	}
+
+	recipients, err := db.GetEscalationRecipients(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(recipients) == 0 {
+		slog.WarnContext(ctx, "overdue claims not escalated: no claim reviewer has an email address", "overdue", len(claims))
+		return 0, nil
+	}
+	to := make([]string, len(recipients))
+	for i, user := range recipients {
+		to[i] = user.Email
+	}
+
+	var escalated []models.Claim
+	for _, claim := range claims {
+		recorded, err := db.RecordClaimEscalation(ctx, claim)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to record claim escalation", "claim_id", claim.ID, "error", err)
+			continue
+		}
+		if recorded {
+			escalated = append(escalated, claim)
+		}
+	}
+	if len(escalated) == 0 {
+		return 0, nil
+	}
+
+	if err := utils.SendClaimEscalationEmail(to, escalated); err != nil {
+		metrics.EmailSendFailures.WithLabelValues("claim_escalation").Inc()
+		for _, claim := range escalated {
+			if err := db.ReleaseClaimEscalation(ctx, claim); err != nil {
+				slog.ErrorContext(ctx, "failed to release claim escalation", "claim_id", claim.ID, "error", err)
+			}
+		}
+		return 0, err
+	}
+
+	for _, claim := range escalated {
+		metrics.ClaimsEscalated.WithLabelValues(string(claim.Status)).Inc()
+		slog.InfoContext(ctx, "overdue claim escalated", "claim_id", claim.ID, "tracking_ref", claim.TrackingRef,
+			"status", claim.Status, "status_changed_at", claim.StatusChangedAt)
+	}
+	return len(escalated), nil
+}
+
+// StartClaimEscalations escalates overdue claims now and then every interval
+// until the server shuts down. Running it on several instances is safe; each
+// escalation is recorded before it is sent.
+func StartClaimEscalations(interval time.Duration) {
+	if interval <= 0 || len(models.ClaimSLATargets()) == 0 {
+		slog.Info("claim escalation scheduler disabled")
+		return
+	}
+
+	utils.RunWorker("claim escalations", func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			escalated, err := EscalateOverdueClaims(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("claim escalation run failed", "error", err)
+			} else if escalated > 0 {
+				slog.Info("overdue claims escalated", "escalated", escalated)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
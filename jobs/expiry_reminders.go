@@ -0,0 +1,110 @@
+// Package jobs holds scheduled work that runs inside the server or once from
+// the command line.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/metrics"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/notify"
+	"tayaria-warranty-be/utils"
+)
+
+// SendExpiryReminders sends every reminder that is due for the given windows
+// (days before expiry) and returns how many were sent. A reminder that fails
+// to send is logged and tried again on the next run.
+func SendExpiryReminders(ctx context.Context, windows []int) (int, error) {
+	// Smallest window first: a warranty inside several windows gets only the
+	// most urgent reminder
+	windows = append([]int(nil), windows...)
+	sort.Ints(windows)
+
+	today := models.Today()
+	sent := 0
+	for _, daysBefore := range windows {
+		warranties, err := db.GetWarrantiesDueForReminder(ctx, daysBefore, today)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, warranty := range warranties {
+			if err := ctx.Err(); err != nil {
+				return sent, err
+			}
+			if sendExpiryReminder(ctx, warranty, daysBefore, today) {
+				sent++
+			}
+		}
+	}
+
+	return sent, nil
+}
+
+// sendExpiryReminder sends one reminder unless another run already did, and
+// reports whether it was sent
+func sendExpiryReminder(ctx context.Context, warranty models.Warranty, daysBefore int, today models.Date) bool {
+	channel := notify.ChannelFor(ctx, warranty.PhoneNumber, warranty.Email)
+	if channel == "" {
+		slog.WarnContext(ctx, "no way to send warranty reminder", "warranty_id", warranty.ID)
+		return false
+	}
+
+	claimed, err := db.ClaimWarrantyReminder(ctx, warranty.ID, daysBefore, string(channel))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record warranty reminder", "warranty_id", warranty.ID, "error", err)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	daysLeft := warranty.ExpiryDate.DaysSince(today)
+	if err := notify.WarrantyReminder(ctx, channel, warranty, daysLeft); err != nil {
+		slog.ErrorContext(ctx, "failed to send warranty reminder", "warranty_id", warranty.ID, "days_before", daysBefore, "channel", channel, "error", err)
+		if err := db.ReleaseWarrantyReminder(ctx, warranty.ID, daysBefore); err != nil {
+			slog.ErrorContext(ctx, "failed to release warranty reminder", "warranty_id", warranty.ID, "error", err)
+		}
+		return false
+	}
+
+	metrics.WarrantyRemindersSent.WithLabelValues(strconv.Itoa(daysBefore)).Inc()
+	slog.InfoContext(ctx, "warranty reminder sent", "warranty_id", warranty.ID, "days_before", daysBefore, "channel", channel,
+		"expiry_date", warranty.ExpiryDate.String())
+	return true
+}
+
+// StartExpiryReminders sends due reminders now and then every interval until
+// the server shuts down. Running it on several instances is safe; each
+// reminder is recorded before it is sent.
+func StartExpiryReminders(interval time.Duration, windows []int) {
+	if interval <= 0 || len(windows) == 0 {
+		slog.Info("expiry reminder scheduler disabled")
+		return
+	}
+
+	utils.RunWorker("expiry reminders", func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sent, err := SendExpiryReminders(ctx, windows)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("expiry reminder run failed", "sent", sent, "error", err)
+			} else if sent > 0 {
+				slog.Info("expiry reminders sent", "sent", sent)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"tayaria-warranty-be/config"
 	"tayaria-warranty-be/db"
 	"tayaria-warranty-be/handlers"
+	"tayaria-warranty-be/jobs"
+	"tayaria-warranty-be/metrics"
 	"tayaria-warranty-be/middleware"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/notify"
+	"tayaria-warranty-be/storage"
+	"tayaria-warranty-be/utils"
+	"time"
+	_ "time/tzdata" // business timezone must load on hosts without a tz database
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,28 +31,91 @@ func main() {
 		log.Fatal("Failed to initialize config:", err)
 	}
 
+	// Switch to structured JSON logging
+	utils.InitLogger(config.AppConfig.LogLevel)
+
+	// Purchase and expiry dates are business calendar days
+	models.SetBusinessLocation(config.AppConfig.BusinessLocation)
+
+	// Claims staying longer than these in a status are overdue
+	models.SetClaimSLA(models.ClaimSLA{
+		models.CustomerSubmittedStatus: config.AppConfig.ClaimSLACustomerSubmitted,
+		models.UnacknowledgedStatus:    config.AppConfig.ClaimSLAUnacknowledged,
+		models.PendingStatus:           config.AppConfig.ClaimSLAPending,
+	})
+
+	// Load token signing and verification keys
+	if err := utils.InitJWTKeys(config.AppConfig.JWTSigningKey, config.AppConfig.JWTVerificationKeys); err != nil {
+		fatal("failed to initialize JWT keys", err)
+	}
+	// Legacy tokens stop being accepted once every token issued before the
+	// cutoff would have expired
+	if len(config.AppConfig.JWTLegacySecret) > 0 {
+		maxAge := max(config.AppConfig.ShopTokenTTL, config.AppConfig.MasterTokenTTL)
+		if !utils.SetLegacyTokens(config.AppConfig.JWTLegacySecret, config.AppConfig.JWTLegacyCutoff, maxAge) {
+			slog.Warn("legacy HS256 tokens are no longer accepted; JWT_SECRET and JWT_LEGACY_CUTOFF can be removed")
+		}
+	}
+
 	// Initialize database
 	if err := db.Init(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		fatal("failed to initialize database", err)
 	}
 
-	// Set up signal handling for graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-quit
-		log.Println("Shutting down server, closing DB pool...")
-		db.Close()
-		os.Exit(0)
-	}()
+	// Apply schema migrations
+	if config.AppConfig.AutoMigrate {
+		if err := db.Migrate(context.Background()); err != nil {
+			fatal("failed to apply migrations", err)
+		}
+	}
+
+	// Email for warranty, claim and escalation notices
+	if config.AppConfig.SMTPPassword == "" {
+		slog.Warn("SMTP_PASSWORD is not set, emails will not be sent")
+	}
+	utils.InitMailer(config.AppConfig.SMTPPassword)
+
+	// SMS/WhatsApp for customer notifications
+	gateway := notify.GatewayConfig{
+		URL:      config.AppConfig.MessagingGatewayURL,
+		Token:    config.AppConfig.MessagingGatewayToken,
+		SenderID: config.AppConfig.MessagingSenderID,
+	}
+	if err := notify.Init(config.AppConfig.MessagingBackend, gateway, config.AppConfig.PhoneCountryCode); err != nil {
+		fatal("failed to initialize messaging", err)
+	}
+
+	// One-shot commands, e.g. from cron, run instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			fatal("command failed", err)
+		}
+		return
+	}
+
+	// Storage for uploaded claim photos
+	if err := storage.Init(config.AppConfig.StorageBackend, config.AppConfig.StorageLocalDir); err != nil {
+		fatal("failed to initialize storage", err)
+	}
 
-	r := gin.Default()
+	r := gin.New()
+	// Only trust X-Forwarded-For from the configured proxies, otherwise
+	// clients could pick their own IP and dodge the per-IP rate limits
+	if err := r.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		fatal("invalid TRUSTED_PROXIES", err)
+	}
+	r.TrustedPlatform = config.AppConfig.TrustedPlatform
+	r.Use(gin.Recovery())
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.RequestLoggerMiddleware())
+	r.Use(middleware.MetricsMiddleware())
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -50,57 +125,169 @@ func main() {
 
 	// Health check
 	r.GET("/api/ping", handlers.Ping)
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz)
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+	r.GET("/metrics", middleware.MetricsAuthMiddleware(config.AppConfig.MetricsToken), gin.WrapH(metrics.Handler()))
+
+	// Rate limiting for public endpoints
+	rateLimitStore := middleware.NewMemoryRateLimitStore(10 * time.Minute)
+	loginRateLimits := []gin.HandlerFunc{
+		middleware.RateLimitMiddleware(rateLimitStore, "login-ip",
+			middleware.RateLimit{PerMinute: config.AppConfig.LoginRateLimitPerIP, Burst: config.AppConfig.LoginRateLimitPerIP},
+			middleware.KeyByIP),
+		middleware.RateLimitMiddleware(rateLimitStore, "login-username",
+			middleware.RateLimit{PerMinute: config.AppConfig.LoginRateLimitPerUsername, Burst: config.AppConfig.LoginRateLimitPerUsername},
+			middleware.KeyByLoginUsername),
+	}
 
 	// Public auth routes
-	r.POST("/api/admin/login", handlers.AdminLogin)
-	r.POST("/api/master/login", handlers.MasterLogin)
+	r.POST("/api/admin/login", append(loginRateLimits, handlers.AdminLogin)...)
+	r.POST("/api/master/login", append(loginRateLimits, handlers.MasterLogin)...)
+
+	// Two-factor setup, also reachable with the enrollment token issued at
+	// login. Rate limited like login since it accepts codes.
+	twoFactorRoutes := r.Group("/api/auth/2fa")
+	twoFactorRoutes.Use(
+		middleware.RateLimitMiddleware(rateLimitStore, "2fa-ip",
+			middleware.RateLimit{PerMinute: config.AppConfig.LoginRateLimitPerIP, Burst: config.AppConfig.LoginRateLimitPerIP},
+			middleware.KeyByIP),
+		middleware.EnrollmentAuthMiddleware(),
+	)
+	{
+		twoFactorRoutes.POST("/enroll", handlers.EnrollTwoFactor)
+		twoFactorRoutes.POST("/verify", handlers.VerifyTwoFactor)
+		twoFactorRoutes.POST("/recovery-codes", handlers.RegenerateRecoveryCodes)
+		twoFactorRoutes.POST("/disable", handlers.DisableTwoFactor)
+	}
 
 	// User routes (public, no auth middleware)
 	userRoutes := r.Group("/api/user")
+	userRoutes.Use(middleware.RateLimitMiddleware(rateLimitStore, "public-ip",
+		middleware.RateLimit{PerMinute: config.AppConfig.PublicRateLimitPerIP, Burst: config.AppConfig.PublicRateLimitPerIP},
+		middleware.KeyByIP))
 	{
 		userRoutes.POST("/warranty", handlers.RegisterWarranty)
 		userRoutes.GET("/warranties/car-plate/:carPlate", handlers.GetWarrantiesByCarPlate) // this is for User Warranty Check
 		userRoutes.GET("/warranties/valid/:carPlate", handlers.HasValidWarrantyByCarPlate)
 		userRoutes.GET("/warranty/receipt/:id", handlers.GetWarrantyReceipt)
+		// customer self-service claims
+		userRoutes.GET("/shops", handlers.GetClaimShops)
+		userRoutes.POST("/claim", handlers.SubmitCustomerClaim)
+		userRoutes.POST("/claim/track", handlers.TrackClaim)
 	}
 
-	// Admin routes (protected)
+	// Admin routes (protected, shop staff)
 	adminRoutes := r.Group("/api/admin")
-	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.Use(middleware.AuthMiddleware())
 	{
 		// Claim management (moved from user routes)
-		adminRoutes.POST("/claim", handlers.CreateClaim)
-		adminRoutes.GET("/claims", handlers.GetShopClaims)
-		adminRoutes.POST("/claim/:id/close", handlers.CloseClaim)
+		adminRoutes.POST("/claim", middleware.RequirePermission(models.PermCreateClaim), handlers.CreateClaim)
+		adminRoutes.GET("/claims", middleware.RequirePermission(models.PermReadShopClaims), handlers.GetShopClaims)
+		adminRoutes.GET("/dashboard", middleware.RequirePermission(models.PermReadShopClaims), handlers.GetShopDashboard)
+		adminRoutes.POST("/claim/:id/close", middleware.RequirePermission(models.PermCloseClaim), handlers.CloseClaim)
+		adminRoutes.POST("/claim/:id/accept-submission", middleware.RequirePermission(models.PermCreateClaim), handlers.AcceptCustomerClaim)
+		adminRoutes.PUT("/claim/:id/inspections", middleware.RequirePermission(models.PermCreateClaim), handlers.UpdateClaimInspections)
+		adminRoutes.POST("/claim/:id/photos", middleware.RequirePermission(models.PermCreateClaim), handlers.UploadClaimPhotos)
+		adminRoutes.GET("/claim/:id/photos", middleware.RequirePermission(models.PermReadShopClaims), handlers.GetClaimPhotos)
+		adminRoutes.GET("/claim/:id/photos/:photoId", middleware.RequirePermission(models.PermReadShopClaims), handlers.GetClaimPhotoFile)
+		// staff management for the caller's shop
+		adminRoutes.GET("/users", middleware.RequirePermission(models.PermManageShopUsers), handlers.GetUsers)
+		adminRoutes.POST("/users", middleware.RequirePermission(models.PermManageShopUsers), handlers.CreateUser)
+		adminRoutes.PUT("/users/:id", middleware.RequirePermission(models.PermManageShopUsers), handlers.UpdateUser)
 	}
 
-	// Master admin routes (protected)
+	// Master admin routes (protected, headquarters staff)
 	masterRoutes := r.Group("/api/master")
-	masterRoutes.Use(middleware.MasterMiddleware())
+	masterRoutes.Use(middleware.AuthMiddleware())
 	{
 		// claim management
-		masterRoutes.GET("/claims", handlers.GetAllClaims)
-		masterRoutes.GET("/claim/:id", handlers.GetClaimInfoByID)
-		masterRoutes.POST("/claim/:id/tag-warranty", handlers.TagWarrantyToClaim)
-		masterRoutes.POST("/claim/:id/change-status", handlers.ChangeClaimStatus)
-		masterRoutes.POST("/claim/:id/pending", handlers.ChangeClaimStatusToPending)
-		masterRoutes.POST("/claim/:id/accept", handlers.ChangeClaimStatusToAccepted)
-		masterRoutes.POST("/claim/:id/reject", handlers.ChangeClaimStatusToRejected)
+		masterRoutes.GET("/claims", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetAllClaims)
+		masterRoutes.GET("/claims/export", middleware.RequirePermission(models.PermReadAllClaims), handlers.ExportClaims)
+		masterRoutes.GET("/claim/:id", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetClaimInfoByID)
+		masterRoutes.GET("/claim/:id/photos/:photoId", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetClaimPhotoFile)
+		masterRoutes.POST("/claim/:id/tag-warranty", middleware.RequirePermission(models.PermReviewClaims), handlers.TagWarrantyToClaim)
+		masterRoutes.POST("/claim/:id/change-status", middleware.RequirePermission(models.PermReviewClaims), handlers.ChangeClaimStatus)
+		masterRoutes.POST("/claim/:id/pending", middleware.RequirePermission(models.PermReviewClaims), handlers.ChangeClaimStatusToPending)
+		masterRoutes.POST("/claim/:id/accept", middleware.RequirePermission(models.PermReviewClaims), handlers.ChangeClaimStatusToAccepted)
+		masterRoutes.POST("/claim/:id/reject", middleware.RequirePermission(models.PermReviewClaims), handlers.ChangeClaimStatusToRejected)
 		// warranty management
-		masterRoutes.GET("/warranties/valid/:carPlate", handlers.GetValidWarrantiesForTagging)
+		masterRoutes.GET("/warranties", middleware.RequirePermission(models.PermReadAllWarranties), handlers.SearchWarranties)
+		masterRoutes.GET("/warranties/export", middleware.RequirePermission(models.PermReadAllWarranties), handlers.ExportWarranties)
+		masterRoutes.POST("/warranties/import", middleware.RequirePermission(models.PermManageWarranties), handlers.ImportWarranties)
+		masterRoutes.GET("/warranties/imports/:id/errors", middleware.RequirePermission(models.PermManageWarranties), handlers.GetWarrantyImportErrors)
+		masterRoutes.GET("/warranties/review-queue", middleware.RequirePermission(models.PermReadAllWarranties), handlers.GetWarrantyReviewQueue)
+		masterRoutes.GET("/warranty/:id", middleware.RequirePermission(models.PermReadAllWarranties), handlers.GetWarrantyByID)
+		masterRoutes.GET("/warranty/:id/audit", middleware.RequirePermission(models.PermReadAllWarranties), handlers.GetWarrantyAuditLog)
+		masterRoutes.PUT("/warranty/:id", middleware.RequirePermission(models.PermManageWarranties), handlers.UpdateWarranty)
+		masterRoutes.POST("/warranty/:id/void", middleware.RequirePermission(models.PermManageWarranties), handlers.VoidWarranty)
+		masterRoutes.POST("/warranty/:id/transfer", middleware.RequirePermission(models.PermManageWarranties), handlers.TransferWarranty)
+		masterRoutes.POST("/warranty/:id/clear-review", middleware.RequirePermission(models.PermManageWarranties), handlers.ClearWarrantyReview)
+		masterRoutes.GET("/warranties/valid/:carPlate", middleware.RequirePermission(models.PermReadAllWarranties), handlers.GetValidWarrantiesForTagging)
+		// reporting
+		masterRoutes.GET("/reports/claims-by-shop", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetClaimsByShopReport)
+		masterRoutes.GET("/reports/claim-outcomes", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetClaimOutcomesReport)
+		masterRoutes.GET("/reports/rejection-reasons", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetRejectionReasonsReport)
+		masterRoutes.GET("/reports/sla", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetSLAReport)
+		masterRoutes.GET("/reports/tyres", middleware.RequirePermission(models.PermReadAllClaims), handlers.GetTyresReport)
+		masterRoutes.GET("/reports/registrations", middleware.RequirePermission(models.PermReadAllWarranties), handlers.GetRegistrationsReport)
 		// retail account management
-		masterRoutes.POST("/account", handlers.CreateRetailAccount)
-		masterRoutes.GET("/account", handlers.GetRetailAccounts)
+		masterRoutes.POST("/account", middleware.RequirePermission(models.PermManageShops), handlers.CreateRetailAccount)
+		masterRoutes.GET("/account", middleware.RequirePermission(models.PermManageShops), handlers.GetRetailAccounts)
+		// user management across all shops
+		masterRoutes.GET("/users", middleware.RequirePermission(models.PermManageAllUsers), handlers.GetUsers)
+		masterRoutes.POST("/users", middleware.RequirePermission(models.PermManageAllUsers), handlers.CreateUser)
+		masterRoutes.PUT("/users/:id", middleware.RequirePermission(models.PermManageAllUsers), handlers.UpdateUser)
+		masterRoutes.POST("/users/:id/2fa/reset", middleware.RequirePermission(models.PermManageAllUsers), handlers.ResetUserTwoFactor)
 	}
 
-	// Get port from environment variable or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := &http.Server{
+		Addr:    ":" + config.AppConfig.Port,
+		Handler: r,
 	}
 
 	// Start server
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	go func() {
+		slog.Info("listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("failed to start server", err)
+		}
+	}()
+
+	// Scheduled jobs
+	jobs.StartExpiryReminders(config.AppConfig.ExpiryReminderInterval, config.AppConfig.ExpiryReminderDays)
+	jobs.StartClaimEscalations(config.AppConfig.ClaimEscalationInterval)
+
+	// Wait for a shutdown signal (Render sends SIGTERM on deploy)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	shutdown(srv)
+	slog.Info("server stopped")
+}
+
+// shutdown stops accepting connections, drains in-flight requests and
+// background tasks, and only then closes the DB pool. Everything shares one
+// drain deadline.
+func shutdown(srv *http.Server) {
+	slog.Info("shutting down server", "drain_timeout", config.AppConfig.ShutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), config.AppConfig.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("http server did not drain cleanly", "error", err)
 	}
+
+	if err := utils.StopBackgroundTasks(ctx); err != nil {
+		slog.Error("background tasks did not finish before timeout", "error", err)
+	}
+
+	slog.Info("closing db pool")
+	db.Close()
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
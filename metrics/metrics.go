@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tayaria"
+
+var registry = prometheus.NewRegistry()
+
+// HTTP metrics, labelled by Gin route template rather than raw path so that
+// car plates and IDs do not create unbounded label values
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests processed, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+)
+
+// Domain metrics
+var (
+	WarrantiesRegistered = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "warranties_registered_total",
+		Help:      "Warranties registered by customers.",
+	})
+
+	ClaimsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "claims_created_total",
+		Help:      "Claims filed, by shop.",
+	}, []string{"shop_id"})
+
+	ClaimDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "claim_decisions_total",
+		Help:      "Claims settled by master users, by decision (approved, rejected).",
+	}, []string{"decision"})
+
+	EmailSendFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_send_failures_total",
+		Help:      "Emails that could not be sent, by email type.",
+	}, []string{"type"})
+
+	MessageSendFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "message_send_failures_total",
+		Help:      "SMS and WhatsApp messages that could not be sent, by channel and message type.",
+	}, []string{"channel", "type"})
+
+	WarrantyRemindersSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "warranty_reminders_sent_total",
+		Help:      "Warranty expiry reminders sent, by days before expiry.",
+	}, []string{"days_before"})
+
+	ClaimsEscalated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "claims_escalated_total",
+		Help:      "Overdue claims escalated to headquarters, by the status they were overdue in.",
+	}, []string{"status"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		WarrantiesRegistered,
+		ClaimsCreated,
+		ClaimDecisions,
+		EmailSendFailures,
+		MessageSendFailures,
+		WarrantyRemindersSent,
+		ClaimsEscalated,
+		newPoolCollector(),
+	)
+}
+
+// Register adds extra collectors to the registry served by Handler
+func Register(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
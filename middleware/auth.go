@@ -1,93 +1,136 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 	"strings"
+	"tayaria-warranty-be/db"
 	"tayaria-warranty-be/models"
 	"tayaria-warranty-be/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AdminMiddleware() gin.HandlerFunc {
+// AuthMiddleware validates the bearer token and stores the caller's identity
+// in the context. Access to individual routes is checked by
+// RequirePermission. Tokens limited to 2FA enrollment are rejected.
+func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+		claims, ok := authenticate(c)
+		if !ok {
 			return
 		}
 
-		// Check if it's a Bearer token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+		if claims.Scope == utils.ScopeMFAEnrollment {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                          "Two-factor authentication must be set up first",
+				"two_factor_enrollment_required": true,
+			})
 			c.Abort()
 			return
 		}
 
-		// Validate the token
-		claims, err := utils.ValidateToken(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		c.Next()
+	}
+}
+
+// EnrollmentAuthMiddleware is AuthMiddleware for the 2FA routes, which also
+// accept tokens limited to 2FA enrollment
+func EnrollmentAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
 			return
 		}
 
-		// Check if user has admin role
-		if claims.Role != string(models.AdminRole) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		if claims.Scope != "" && claims.Scope != utils.ScopeMFAEnrollment {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token scope not allowed"})
 			c.Abort()
 			return
 		}
 
-		// Store claims in context for later use
-		c.Set("shop_id", claims.ShopID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
-
 		c.Next()
 	}
 }
 
-func MasterMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
-		}
+// Account lookups used to check the token's user, swapped out in tests
+var (
+	getUserByID       = db.GetUserByID
+	getUserByUsername = db.GetUserByUsername
+)
 
-		// Check if it's a Bearer token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
-			c.Abort()
-			return
-		}
+// authenticate validates the bearer token, loads the user it was issued to
+// and stores the caller's identity in the context. The role comes from the
+// database, so deactivating or demoting a user takes effect immediately
+// rather than when their token expires. It responds and aborts if the token
+// is missing or invalid or the user is gone or inactive.
+func authenticate(c *gin.Context) (*utils.Claims, bool) {
+	// Get the Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		c.Abort()
+		return nil, false
+	}
 
-		// Validate the token
-		claims, err := utils.ValidateToken(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+	// Check if it's a Bearer token
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+		c.Abort()
+		return nil, false
+	}
 
-		// Check if user has master role
-		if claims.Role != string(models.MasterRole) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Master access required"})
-			c.Abort()
-			return
-		}
+	// Validate the token
+	claims, err := utils.ValidateToken(parts[1])
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return nil, false
+	}
 
-		// Store claims in context for later use
-		c.Set("shop_id", claims.ShopID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+	// Tokens from before per-user accounts carry the shop's ID and a
+	// shop-level role; the shop login became a user with the same username
+	var user *models.User
+	if models.UserRole(claims.Role).IsValid() {
+		user, err = getUserByID(c.Request.Context(), claims.UserID)
+	} else {
+		user, err = getUserByUsername(c.Request.Context(), claims.Username)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to load token user", "user_id", claims.UserID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify account"})
+		c.Abort()
+		return nil, false
+	}
+	if user == nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled or no longer exists"})
+		c.Abort()
+		return nil, false
+	}
+
+	// Store the caller's identity in context for later use
+	c.Set("user_id", user.ID)
+	c.Set("shop_id", user.ShopID)
+	c.Set("username", user.Username)
+	c.Set("role", string(user.Role))
+	c.Set("token_scope", claims.Scope)
+
+	return claims, true
+}
+
+// RequirePermission allows the request only if the caller's role grants all
+// of the given permissions. It must run after AuthMiddleware.
+func RequirePermission(permissions ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := models.UserRole(c.GetString("role"))
+		for _, permission := range permissions {
+			if !role.Can(permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied: " + string(permission)})
+				c.Abort()
+				return
+			}
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useFakeUsers serves user lookups from users, keyed by ID
+func useFakeUsers(t *testing.T, users ...*models.User) {
+	t.Helper()
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.InitJWTKeys(signer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	byID, byUsername := getUserByID, getUserByUsername
+	t.Cleanup(func() { getUserByID, getUserByUsername = byID, byUsername })
+
+	getUserByID = func(_ context.Context, id string) (*models.User, error) {
+		for _, user := range users {
+			if user.ID == id {
+				return user, nil
+			}
+		}
+		return nil, nil
+	}
+	getUserByUsername = func(_ context.Context, username string) (*models.User, error) {
+		for _, user := range users {
+			if user.Username == username {
+				return user, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// serveWithToken calls a route requiring permission with a token for the
+// given identity and returns the status and the role the handler saw
+func serveWithToken(t *testing.T, userID, username string, role models.UserRole, permission models.Permission) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	token, err := utils.GenerateToken(userID, "shop-1", username, string(role), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seenRole string
+	r := gin.New()
+	r.GET("/", AuthMiddleware(), RequirePermission(permission), func(c *gin.Context) {
+		seenRole = c.GetString("role")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, seenRole
+}
+
+func TestAuthMiddlewareChecksUserOnEachRequest(t *testing.T) {
+	active := &models.User{ID: "u1", ShopID: "shop-1", Username: "active", Role: models.ShopManagerRole, IsActive: true}
+	disabled := &models.User{ID: "u2", ShopID: "shop-1", Username: "disabled", Role: models.ShopManagerRole, IsActive: false}
+	demoted := &models.User{ID: "u3", ShopID: "shop-1", Username: "demoted", Role: models.ShopStaffRole, IsActive: true}
+	useFakeUsers(t, active, disabled, demoted)
+
+	if code, role := serveWithToken(t, "u1", "active", models.ShopManagerRole, models.PermManageShopUsers); code != http.StatusOK || role != string(models.ShopManagerRole) {
+		t.Errorf("active user: status %d, role %q", code, role)
+	}
+	if code, _ := serveWithToken(t, "u2", "disabled", models.ShopManagerRole, models.PermCreateClaim); code != http.StatusUnauthorized {
+		t.Errorf("disabled user: status %d, want 401", code)
+	}
+	if code, _ := serveWithToken(t, "gone", "gone", models.ShopManagerRole, models.PermCreateClaim); code != http.StatusUnauthorized {
+		t.Errorf("deleted user: status %d, want 401", code)
+	}
+
+	// The token still says shop_manager, but the user was demoted
+	if code, _ := serveWithToken(t, "u3", "demoted", models.ShopManagerRole, models.PermManageShopUsers); code != http.StatusForbidden {
+		t.Errorf("demoted user managing users: status %d, want 403", code)
+	}
+	if code, role := serveWithToken(t, "u3", "demoted", models.ShopManagerRole, models.PermCreateClaim); code != http.StatusOK || role != string(models.ShopStaffRole) {
+		t.Errorf("demoted user: status %d, role %q, want 200 as shop_staff", code, role)
+	}
+}
+
+func TestAuthMiddlewareResolvesLegacyTokensByUsername(t *testing.T) {
+	migrated := &models.User{ID: "u1", ShopID: "shop-1", Username: "testshop1", Role: models.ShopManagerRole, IsActive: true}
+	useFakeUsers(t, migrated)
+
+	// Tokens from before per-user accounts carry the shop ID and role
+	code, role := serveWithToken(t, "shop-1", "testshop1", models.AdminRole, models.PermCreateClaim)
+	if code != http.StatusOK || role != string(models.ShopManagerRole) {
+		t.Errorf("legacy token: status %d, role %q, want 200 as shop_manager", code, role)
+	}
+}
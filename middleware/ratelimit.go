@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tayaria-warranty-be/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit describes a token bucket: Burst requests at once, refilled at
+// PerMinute tokens per minute
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimitStore keeps token buckets by key. The in-memory store is enough
+// for a single instance; a shared store (e.g. Redis) can implement the same
+// interface when running more than one.
+type RateLimitStore interface {
+	// Allow takes a token from the bucket for key. When the bucket is empty it
+	// returns false and how long until the next token is available.
+	Allow(key string, limit RateLimit) (bool, time.Duration)
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewMemoryRateLimitStore creates a store and starts a background sweep that
+// drops buckets idle for longer than idleTTL
+func NewMemoryRateLimitStore(idleTTL time.Duration) *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+	utils.RunWorker("rate limit sweep", func(ctx context.Context) {
+		ticker := time.NewTicker(idleTTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				store.sweep(idleTTL)
+			}
+		}
+	})
+	return store
+}
+
+func (s *MemoryRateLimitStore) Allow(key string, limit RateLimit) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	ratePerSecond := float64(limit.PerMinute) / 60
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), lastSeen: now}
+		s.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the last request
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*ratePerSecond)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / ratePerSecond * float64(time.Second))
+	return false, wait
+}
+
+func (s *MemoryRateLimitStore) sweep(idleTTL time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-idleTTL)
+	for key, bucket := range s.buckets {
+		if bucket.lastSeen.Before(cutoff) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimitKeyFunc extracts the key to limit on; an empty key skips limiting
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP limits per client IP
+func KeyByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// KeyByLoginUsername limits per username in a JSON login body. The body is
+// restored so the handler can bind it as usual.
+func KeyByLoginUsername(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(req.Username))
+}
+
+// RateLimitMiddleware rejects requests over limit with 429 and Retry-After.
+// name namespaces the buckets so different limits on the same key do not
+// share tokens. A limit with PerMinute <= 0 disables limiting.
+func RateLimitMiddleware(store RateLimitStore, name string, limit RateLimit, keyFunc RateLimitKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit.PerMinute <= 0 {
+			c.Next()
+			return
+		}
+
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		allowed, retryAfter := store.Allow(name+":"+key, limit)
+		if !allowed {
+			RespondTooManyRequests(c, retryAfter)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RespondTooManyRequests aborts with 429 and a Retry-After header in whole
+// seconds
+func RespondTooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+	c.Abort()
+}
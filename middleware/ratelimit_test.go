@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestStore returns a store whose clock only moves when advance is called
+func newTestStore() (*MemoryRateLimitStore, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := &MemoryRateLimitStore{
+		buckets: map[string]*tokenBucket{},
+		now:     func() time.Time { return now },
+	}
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryRateLimitStoreBurstAndRefill(t *testing.T) {
+	store, advance := newTestStore()
+	limit := RateLimit{PerMinute: 6, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.Allow("ip", limit); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+
+	ok, wait := store.Allow("ip", limit)
+	if ok {
+		t.Fatal("request over burst was allowed")
+	}
+	if wait != 10*time.Second {
+		t.Errorf("retry after = %v, want 10s", wait)
+	}
+
+	advance(5 * time.Second)
+	ok, wait = store.Allow("ip", limit)
+	if ok {
+		t.Fatal("request allowed before a full token refilled")
+	}
+	if wait != 5*time.Second {
+		t.Errorf("retry after = %v, want 5s", wait)
+	}
+
+	advance(5 * time.Second)
+	if ok, _ := store.Allow("ip", limit); !ok {
+		t.Fatal("request rejected after a token refilled")
+	}
+	if ok, _ := store.Allow("ip", limit); ok {
+		t.Fatal("refill granted more than one token")
+	}
+
+	// A long idle period refills up to Burst, not beyond
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.Allow("ip", limit); !ok {
+			t.Fatalf("request %d after idle period was rejected", i+1)
+		}
+	}
+	if ok, _ := store.Allow("ip", limit); ok {
+		t.Fatal("bucket refilled beyond Burst")
+	}
+}
+
+func TestMemoryRateLimitStoreKeysAreIndependent(t *testing.T) {
+	store, _ := newTestStore()
+	limit := RateLimit{PerMinute: 1, Burst: 1}
+
+	if ok, _ := store.Allow("a", limit); !ok {
+		t.Fatal("first request for a was rejected")
+	}
+	if ok, _ := store.Allow("a", limit); ok {
+		t.Fatal("second request for a was allowed")
+	}
+	if ok, _ := store.Allow("b", limit); !ok {
+		t.Fatal("request for b was limited by a's bucket")
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store, advance := newTestStore()
+	limit := RateLimit{PerMinute: 60, Burst: 1}
+
+	store.Allow("idle", limit)
+	advance(10 * time.Minute)
+	store.Allow("active", limit)
+	advance(time.Minute)
+
+	store.sweep(5 * time.Minute)
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := store.buckets["active"]; !ok {
+		t.Error("active bucket was swept")
+	}
+}
+
+func TestKeyByIPIgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{"no trusted proxies", nil, "203.0.113.7"},
+		{"peer is a trusted proxy", []string{"203.0.113.0/24"}, "198.51.100.1"},
+		{"peer is not a trusted proxy", []string{"10.0.0.0/8"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			r.GET("/", func(c *gin.Context) { got = KeyByIP(c) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:4321"
+			req.Header.Set("X-Forwarded-For", "198.51.100.1")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("KeyByIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,12 +5,36 @@ import "time"
 type ClaimStatus string
 
 const (
-	UnacknowledgedStatus ClaimStatus = "unacknowledged"
-	PendingStatus        ClaimStatus = "pending"
-	ApprovedStatus       ClaimStatus = "approved"
-	RejectedStatus       ClaimStatus = "rejected"
+	// CustomerSubmittedStatus is a claim started by a customer that the chosen
+	// shop has not accepted yet
+	CustomerSubmittedStatus ClaimStatus = "customer_submitted"
+	UnacknowledgedStatus    ClaimStatus = "unacknowledged"
+	PendingStatus           ClaimStatus = "pending"
+	ApprovedStatus          ClaimStatus = "approved"
+	RejectedStatus          ClaimStatus = "rejected"
+	// ClosedStatus only appears in customer timelines; closed claims keep
+	// their approved/rejected status and get date_closed
+	ClosedStatus ClaimStatus = "closed"
 )
 
+// customerLabels are the status names shown to customers tracking a claim
+var customerLabels = map[ClaimStatus]string{
+	CustomerSubmittedStatus: "Submitted",
+	UnacknowledgedStatus:    "Received by shop",
+	PendingStatus:           "Under review",
+	ApprovedStatus:          "Approved",
+	RejectedStatus:          "Not approved",
+	ClosedStatus:            "Closed",
+}
+
+// CustomerLabel returns the status name shown to customers
+func (s ClaimStatus) CustomerLabel() string {
+	if label, ok := customerLabels[s]; ok {
+		return label
+	}
+	return string(s)
+}
+
 type TyreDetail struct {
 	ID           string    `json:"id"`
 	ClaimID      string    `json:"claim_id"`
@@ -20,6 +44,45 @@ type TyreDetail struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// TyrePosition says which tyre a claim photo shows
+type TyrePosition string
+
+const (
+	FrontLeftTyre  TyrePosition = "front_left"
+	FrontRightTyre TyrePosition = "front_right"
+	RearLeftTyre   TyrePosition = "rear_left"
+	RearRightTyre  TyrePosition = "rear_right"
+	SpareTyre      TyrePosition = "spare"
+)
+
+// IsValid reports whether p is one of the known tyre positions
+func (p TyrePosition) IsValid() bool {
+	switch p {
+	case FrontLeftTyre, FrontRightTyre, RearLeftTyre, RearRightTyre, SpareTyre:
+		return true
+	}
+	return false
+}
+
+// ClaimPhoto is a damage photo uploaded as evidence for a claim. The image
+// and its thumbnail are served through URL and ThumbnailURL.
+type ClaimPhoto struct {
+	ID           string       `json:"id"`
+	ClaimID      string       `json:"claim_id"`
+	TyrePosition TyrePosition `json:"tyre_position"`
+	ContentType  string       `json:"content_type"`
+	SizeBytes    int64        `json:"size_bytes"`
+	Width        int          `json:"width"`
+	Height       int          `json:"height"`
+	UploadedBy   string       `json:"uploaded_by"`
+	CreatedAt    time.Time    `json:"created_at"`
+	URL          string       `json:"url"`
+	ThumbnailURL string       `json:"thumbnail_url"`
+	// Where the image and thumbnail are kept in storage
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
 type Claim struct {
 	ID              string      `json:"id"`
 	WarrantyID      *string     `json:"warranty_id"`
@@ -35,10 +98,23 @@ type Claim struct {
 	PhoneNumber  string    `json:"phone_number"`
 	Email        string    `json:"email"`
 	CarPlate     string    `json:"car_plate"`
+	Description  string    `json:"description"`
+	PhotoURLs    []string  `json:"photo_urls"`
+	TrackingRef  string    `json:"tracking_ref"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	// When the claim entered its current status, and when it is due to leave
+	// it under the SLA (nil if the status has no target)
+	StatusChangedAt time.Time  `json:"status_changed_at"`
+	SLADueAt        *time.Time `json:"sla_due_at"`
+	SLABreached     bool       `json:"sla_breached"`
 	// Optional field for when we need to include tyre details
 	TyreDetails []TyreDetail `json:"tyre_details,omitempty"`
+	// Damage photos, included for master review
+	Photos []ClaimPhoto `json:"photos,omitempty"`
+	// Shop inspection of the damaged tyres and the warranty pre-check
+	Inspections []TyreInspection  `json:"inspections,omitempty"`
+	Eligibility *EligibilityCheck `json:"eligibility,omitempty"`
 }
 
 // Request models
@@ -47,6 +123,52 @@ type CreateClaimRequest struct {
 	PhoneNumber  string `json:"phone_number" binding:"required"`
 	Email        string `json:"email"`
 	CarPlate     string `json:"car_plate" binding:"required"`
+	// Inspection of the damaged tyres; can also be filled in later
+	Inspections []TyreInspectionRequest `json:"inspections" binding:"omitempty,max=5,dive"`
+}
+
+// CustomerClaimRequest is a claim started from the public site
+type CustomerClaimRequest struct {
+	CustomerName string   `json:"customer_name" binding:"required"`
+	PhoneNumber  string   `json:"phone_number" binding:"required"`
+	Email        string   `json:"email" binding:"omitempty,email"`
+	CarPlate     string   `json:"car_plate" binding:"required"`
+	Description  string   `json:"description" binding:"required,max=2000"`
+	PhotoURLs    []string `json:"photo_urls" binding:"max=10,dive,url"`
+	ShopID       string   `json:"shop_id" binding:"required,uuid"` // preferred shop
+	// NotifyChannel is how the customer wants to hear about the claim
+	NotifyChannel NotificationChannel `json:"notify_channel" binding:"omitempty,oneof=email sms whatsapp"`
+}
+
+// CustomerClaimResponse is what the customer sees after submitting a claim
+type CustomerClaimResponse struct {
+	TrackingRef string      `json:"tracking_ref"`
+	Status      ClaimStatus `json:"status"`
+	ShopName    string      `json:"shop_name"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+type TrackClaimRequest struct {
+	TrackingRef string `json:"tracking_ref" binding:"required"`
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
+
+type ClaimStatusEvent struct {
+	Status ClaimStatus `json:"status"`
+	Label  string      `json:"label"`
+	At     time.Time   `json:"at"`
+}
+
+// ClaimTrackingResponse is the customer's view of a claim. It leaves out
+// internal details such as rejection notes and warranty IDs.
+type ClaimTrackingResponse struct {
+	TrackingRef string             `json:"tracking_ref"`
+	Status      ClaimStatus        `json:"status"`
+	StatusLabel string             `json:"status_label"`
+	CarPlate    string             `json:"car_plate"`
+	ShopName    string             `json:"shop_name"`
+	ShopContact string             `json:"shop_contact"`
+	Timeline    []ClaimStatusEvent `json:"timeline"`
 }
 
 type TagWarrantyRequest struct {
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const dateLayout = "2006-01-02"
+
+// businessLocation is the timezone "today" and incoming timestamps are
+// taken in. It is set once at startup from the configuration.
+var businessLocation = time.UTC
+
+// SetBusinessLocation sets the timezone calendar days are counted in
+func SetBusinessLocation(loc *time.Location) {
+	businessLocation = loc
+}
+
+// Date is a calendar day, such as a purchase or expiry date. It has no time
+// of day or timezone, so it cannot shift a day when converted between them.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the day t falls on in t's own location
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// BusinessDateOf returns the day t falls on in the business timezone
+func BusinessDateOf(t time.Time) Date {
+	return DateOf(t.In(businessLocation))
+}
+
+// BusinessTime returns t in the business timezone, for showing timestamps
+func BusinessTime(t time.Time) time.Time {
+	return t.In(businessLocation)
+}
+
+// BusinessTimezone returns the name of the business timezone, for grouping
+// timestamps by day or month in SQL
+func BusinessTimezone() string {
+	return businessLocation.String()
+}
+
+// Today returns the current day in the business timezone
+func Today() Date {
+	return BusinessDateOf(time.Now())
+}
+
+// ParseDate parses a YYYY-MM-DD date
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+// midnight returns the start of the day in UTC, for date arithmetic
+func (d Date) midnight() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// BusinessStart returns the moment the day starts in the business timezone
+func (d Date) BusinessStart() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, businessLocation)
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+func (d Date) Before(other Date) bool {
+	return d.midnight().Before(other.midnight())
+}
+
+func (d Date) After(other Date) bool {
+	return d.midnight().After(other.midnight())
+}
+
+// DaysSince returns how many days other is before d
+func (d Date) DaysSince(other Date) int {
+	return int(d.midnight().Sub(other.midnight()).Hours() / 24)
+}
+
+// AddDays returns the day n days after d
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.midnight().AddDate(0, 0, n))
+}
+
+// AddMonths returns the same day n months after d, or the last day of that
+// month if it is shorter: Aug 31 + 6 months is Feb 28 (29 in leap years),
+// not Mar 3 as time.AddDate would give. This matches Postgres
+// date + INTERVAL 'n months'.
+func (d Date) AddMonths(n int) Date {
+	first := time.Date(d.Year, d.Month+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := d.Day
+	if day > lastDay {
+		day = lastDay
+	}
+	return Date{Year: first.Year(), Month: first.Month(), Day: day}
+}
+
+// Format formats the day with a time.Format layout
+func (d Date) Format(layout string) string {
+	return d.midnight().Format(layout)
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+// MarshalJSON writes the date as "YYYY-MM-DD", or null if it is unset
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads "YYYY-MM-DD". A full timestamp, as browsers send from
+// date pickers, is taken as the day it falls on in the business timezone.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("date must be a string: %v", err)
+	}
+	if date, err := ParseDate(s); err == nil {
+		*d = date
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("date must be YYYY-MM-DD: %q", s)
+	}
+	*d = BusinessDateOf(t)
+	return nil
+}
+
+// ScanDate reads a Postgres DATE
+func (d *Date) ScanDate(v pgtype.Date) error {
+	if !v.Valid {
+		*d = Date{}
+		return nil
+	}
+	if v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("cannot scan infinite date")
+	}
+	*d = DateOf(v.Time)
+	return nil
+}
+
+// DateValue writes the date as a Postgres DATE, NULL if it is unset
+func (d Date) DateValue() (pgtype.Date, error) {
+	if d.IsZero() {
+		return pgtype.Date{}, nil
+	}
+	return pgtype.Date{Time: d.midnight(), Valid: true}, nil
+}
+
+// TextValue writes the date as text, which the simple query protocol sends
+// for every argument
+func (d Date) TextValue() (pgtype.Text, error) {
+	if d.IsZero() {
+		return pgtype.Text{}, nil
+	}
+	return pgtype.Text{String: d.String(), Valid: true}, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDateAddMonths(t *testing.T) {
+	tests := []struct {
+		from   string
+		months int
+		want   string
+	}{
+		{"2025-01-15", 6, "2025-07-15"},
+		{"2025-08-31", 6, "2026-02-28"},
+		{"2023-08-31", 6, "2024-02-29"}, // leap year
+		{"2025-03-31", 6, "2025-09-30"},
+		{"2025-07-31", 6, "2026-01-31"},
+		{"2025-12-31", 2, "2026-02-28"},
+		{"2024-02-29", 12, "2025-02-28"},
+	}
+	for _, tt := range tests {
+		from, _ := ParseDate(tt.from)
+		if got := from.AddMonths(tt.months).String(); got != tt.want {
+			t.Errorf("%s + %d months = %s, want %s", tt.from, tt.months, got, tt.want)
+		}
+	}
+}
+
+func TestBusinessDateOf(t *testing.T) {
+	kl, err := time.LoadLocation("Asia/Kuala_Lumpur")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	SetBusinessLocation(kl)
+	defer SetBusinessLocation(time.UTC)
+
+	tests := []struct {
+		at   string
+		want string
+	}{
+		// 00:30 on 1 September in Malaysia is still 31 August in UTC
+		{"2025-08-31T16:30:00Z", "2025-09-01"},
+		{"2025-08-31T15:59:59Z", "2025-08-31"},
+		{"2026-02-28T16:00:00Z", "2026-03-01"},
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		if got := BusinessDateOf(at).String(); got != tt.want {
+			t.Errorf("BusinessDateOf(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+
+		var d Date
+		if err := json.Unmarshal([]byte(`"`+tt.at+`"`), &d); err != nil || d.String() != tt.want {
+			t.Errorf("unmarshal %s = %s (%v), want %s", tt.at, d, err, tt.want)
+		}
+	}
+}
+
+func TestWarrantyActiveOn(t *testing.T) {
+	purchase, _ := ParseDate("2025-08-31")
+	warranty := Warranty{PurchaseDate: purchase, ExpiryDate: purchase.AddMonths(WarrantyMonths)}
+
+	tests := []struct {
+		day  string
+		want bool
+	}{
+		{"2025-08-31", true},
+		{"2026-02-28", true}, // last day of cover
+		{"2026-03-01", false},
+	}
+	for _, tt := range tests {
+		day, _ := ParseDate(tt.day)
+		if got := warranty.ActiveOn(day); got != tt.want {
+			t.Errorf("ActiveOn(%s) = %v, want %v", tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestRegistrationWindow(t *testing.T) {
+	window := RegistrationWindow{MaxDaysAfterPurchase: 30}
+	today, _ := ParseDate("2025-03-01")
+
+	tests := []struct {
+		purchase string
+		ok       bool
+	}{
+		{"2025-03-01", true},
+		{"2025-03-02", false}, // future
+		{"2025-01-30", true},  // 30 days before
+		{"2025-01-29", false},
+	}
+	for _, tt := range tests {
+		purchase, _ := ParseDate(tt.purchase)
+		if got := window.Check(purchase, today) == nil; got != tt.ok {
+			t.Errorf("Check(%s) ok = %v, want %v", tt.purchase, got, tt.ok)
+		}
+	}
+	if window.Check(Date{}, today) == nil {
+		t.Error("missing purchase date accepted")
+	}
+}
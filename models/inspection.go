@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MinTreadDepthMM is the tread depth a tyre must have more than to be covered
+// by the warranty
+const MinTreadDepthMM = 6.0
+
+type DamageType string
+
+const (
+	PunctureDamage        DamageType = "puncture"
+	CutDamage             DamageType = "cut"
+	SidewallBulgeDamage   DamageType = "sidewall_bulge"
+	ImpactBreakDamage     DamageType = "impact_break"
+	TreadSeparationDamage DamageType = "tread_separation"
+	OtherDamage           DamageType = "other"
+)
+
+// IsValid reports whether d is one of the known damage types
+func (d DamageType) IsValid() bool {
+	switch d {
+	case PunctureDamage, CutDamage, SidewallBulgeDamage, ImpactBreakDamage, TreadSeparationDamage, OtherDamage:
+		return true
+	}
+	return false
+}
+
+// PunctureLocation is where on the tyre a puncture is
+type PunctureLocation string
+
+const (
+	TreadPuncture    PunctureLocation = "tread"
+	ShoulderPuncture PunctureLocation = "shoulder"
+	SidewallPuncture PunctureLocation = "sidewall"
+)
+
+// IsValid reports whether l is one of the known puncture locations
+func (l PunctureLocation) IsValid() bool {
+	switch l {
+	case TreadPuncture, ShoulderPuncture, SidewallPuncture:
+		return true
+	}
+	return false
+}
+
+// TyreInspection is what the shop found when inspecting a damaged tyre
+type TyreInspection struct {
+	ID               string           `json:"id"`
+	ClaimID          string           `json:"claim_id"`
+	TyrePosition     TyrePosition     `json:"tyre_position"`
+	TreadDepthMM     float64          `json:"tread_depth_mm"`
+	DamageType       DamageType       `json:"damage_type"`
+	PunctureLocation PunctureLocation `json:"puncture_location,omitempty"`
+	Repairable       bool             `json:"repairable"`
+	Notes            string           `json:"notes"`
+	CreatedAt        time.Time        `json:"created_at"`
+}
+
+type TyreInspectionRequest struct {
+	TyrePosition     TyrePosition     `json:"tyre_position" binding:"required"`
+	TreadDepthMM     *float64         `json:"tread_depth_mm" binding:"required,gte=0,lte=30"`
+	DamageType       DamageType       `json:"damage_type" binding:"required"`
+	PunctureLocation PunctureLocation `json:"puncture_location"`
+	Repairable       *bool            `json:"repairable" binding:"required"`
+	Notes            string           `json:"notes" binding:"max=1000"`
+}
+
+type UpdateInspectionsRequest struct {
+	Inspections []TyreInspectionRequest `json:"inspections" binding:"required,min=1,max=5,dive"`
+}
+
+// ToInspections checks inspection requests against the warranty policy and
+// converts them. The error is suitable to show to the shop.
+func ToInspections(reqs []TyreInspectionRequest) ([]TyreInspection, error) {
+	seen := map[TyrePosition]bool{}
+	inspections := make([]TyreInspection, 0, len(reqs))
+	for _, req := range reqs {
+		if !req.TyrePosition.IsValid() {
+			return nil, fmt.Errorf("invalid tyre_position %q", req.TyrePosition)
+		}
+		if seen[req.TyrePosition] {
+			return nil, fmt.Errorf("%s is inspected more than once", req.TyrePosition)
+		}
+		seen[req.TyrePosition] = true
+
+		if !req.DamageType.IsValid() {
+			return nil, fmt.Errorf("%s: invalid damage_type %q", req.TyrePosition, req.DamageType)
+		}
+		if req.DamageType == PunctureDamage {
+			if !req.PunctureLocation.IsValid() {
+				return nil, fmt.Errorf("%s: puncture_location must be one of tread, shoulder, sidewall", req.TyrePosition)
+			}
+			// Only punctures in the tread can be plugged or patched
+			if req.PunctureLocation != TreadPuncture && *req.Repairable {
+				return nil, fmt.Errorf("%s: a %s puncture cannot be repairable", req.TyrePosition, req.PunctureLocation)
+			}
+		} else if req.PunctureLocation != "" {
+			return nil, fmt.Errorf("%s: puncture_location is only for punctures", req.TyrePosition)
+		}
+
+		inspections = append(inspections, TyreInspection{
+			TyrePosition:     req.TyrePosition,
+			TreadDepthMM:     *req.TreadDepthMM,
+			DamageType:       req.DamageType,
+			PunctureLocation: req.PunctureLocation,
+			Repairable:       *req.Repairable,
+			Notes:            req.Notes,
+		})
+	}
+	return inspections, nil
+}
+
+// EligibilityCheck is an automatic reading of a claim's inspections against
+// the warranty terms. It helps the reviewer; it does not decide the claim.
+type EligibilityCheck struct {
+	Eligible bool `json:"eligible"`
+	// Reasons lists every term an inspected tyre fails
+	Reasons []string `json:"reasons"`
+}
+
+// CheckEligibility applies the warranty terms to the inspected tyres, or
+// returns nil if no tyre was inspected
+func CheckEligibility(inspections []TyreInspection) *EligibilityCheck {
+	if len(inspections) == 0 {
+		return nil
+	}
+
+	check := &EligibilityCheck{Reasons: []string{}}
+	for _, inspection := range inspections {
+		if inspection.TreadDepthMM <= MinTreadDepthMM {
+			check.Reasons = append(check.Reasons, fmt.Sprintf("%s: %.1fmm tread left, warranty requires above %.0fmm",
+				inspection.TyrePosition, inspection.TreadDepthMM, MinTreadDepthMM))
+		}
+		if !inspection.Repairable {
+			check.Reasons = append(check.Reasons, fmt.Sprintf("%s: damage is beyond repair", inspection.TyrePosition))
+		}
+	}
+	check.Eligible = len(check.Reasons) == 0
+	return check
+}
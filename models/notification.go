@@ -0,0 +1,24 @@
+package models
+
+// NotificationChannel is how a customer wants to hear from us
+type NotificationChannel string
+
+const (
+	EmailChannel    NotificationChannel = "email"
+	SMSChannel      NotificationChannel = "sms"
+	WhatsAppChannel NotificationChannel = "whatsapp"
+)
+
+// IsValid reports whether ch is one of the known channels
+func (ch NotificationChannel) IsValid() bool {
+	switch ch {
+	case EmailChannel, SMSChannel, WhatsAppChannel:
+		return true
+	}
+	return false
+}
+
+// IsMessaging reports whether ch delivers to the customer's phone
+func (ch NotificationChannel) IsMessaging() bool {
+	return ch == SMSChannel || ch == WhatsAppChannel
+}
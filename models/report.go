@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+// ReportFilter limits a report to claims or registrations made from From to
+// To inclusive, days in the business timezone. ShopID, when set, limits claim
+// reports to one shop.
+type ReportFilter struct {
+	From   Date   `json:"from"`
+	To     Date   `json:"to"`
+	ShopID string `json:"shop_id,omitempty"`
+}
+
+// Start is the first moment the filter covers
+func (f ReportFilter) Start() time.Time {
+	return f.From.BusinessStart()
+}
+
+// End is the moment after the last one the filter covers
+func (f ReportFilter) End() time.Time {
+	return f.To.AddDays(1).BusinessStart()
+}
+
+// ReportInterval is how a report over time is bucketed
+type ReportInterval string
+
+const (
+	DailyInterval   ReportInterval = "day"
+	WeeklyInterval  ReportInterval = "week"
+	MonthlyInterval ReportInterval = "month"
+)
+
+// IsValid reports whether i is one of the known intervals
+func (i ReportInterval) IsValid() bool {
+	switch i {
+	case DailyInterval, WeeklyInterval, MonthlyInterval:
+		return true
+	}
+	return false
+}
+
+// ShopMonthClaims counts the claims made at a shop in a month
+type ShopMonthClaims struct {
+	ShopID   string `json:"shop_id"`
+	ShopName string `json:"shop_name"`
+	// Month is the first day of the month
+	Month    Date `json:"month"`
+	Claims   int  `json:"claims"`
+	Approved int  `json:"approved"`
+	Rejected int  `json:"rejected"`
+}
+
+// ClaimOutcomes summarizes what happened to the claims made in a period.
+// Rates are shares of the decided (approved or rejected) claims, 0 when none
+// were decided.
+type ClaimOutcomes struct {
+	Total         int                 `json:"total"`
+	ByStatus      map[ClaimStatus]int `json:"by_status"`
+	Approved      int                 `json:"approved"`
+	Rejected      int                 `json:"rejected"`
+	ApprovalRate  float64             `json:"approval_rate"`
+	RejectionRate float64             `json:"rejection_rate"`
+	// AvgHoursToSettle is the average time from creation to approval or
+	// rejection, null when none were decided
+	AvgHoursToSettle *float64 `json:"avg_hours_to_settle"`
+	// AvgHoursToClose is the average time from creation to the shop closing
+	// a decided claim, null when none were closed
+	AvgHoursToClose *float64 `json:"avg_hours_to_close"`
+}
+
+// RejectionReasonCount is how often a rejection reason was given. Reasons
+// are grouped ignoring case and surrounding spaces.
+type RejectionReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// TyreCount is how many tyres of a brand and size were replaced under
+// approved claims
+type TyreCount struct {
+	Brand string `json:"brand"`
+	Size  string `json:"size"`
+	Count int    `json:"count"`
+}
+
+// RegistrationCount is how many warranties were registered in a period
+// starting on Period
+type RegistrationCount struct {
+	Period        Date `json:"period"`
+	Registrations int  `json:"registrations"`
+	// Voided counts the registrations later voided
+	Voided int `json:"voided"`
+}
+
+// ShopDashboard is a shop's view of its claims: what happened to those made
+// in the period and the decided ones it still has to close, whenever made
+type ShopDashboard struct {
+	From             Date                   `json:"from"`
+	To               Date                   `json:"to"`
+	Outcomes         *ClaimOutcomes         `json:"outcomes"`
+	RejectionReasons []RejectionReasonCount `json:"rejection_reasons"`
+	AwaitingClosure  []Claim                `json:"awaiting_closure"`
+}
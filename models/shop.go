@@ -15,12 +15,19 @@ type Shop struct {
 	Address   string    `json:"address" db:"address"`
 	Contact   string    `json:"contact" db:"contact"`
 	Username  string    `json:"username" db:"username"`
-	Password  string    `json:"password" db:"password"`
 	Role      UserRole  `json:"role" db:"role"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// PublicShop is the shop information shown to customers choosing a shop
+type PublicShop struct {
+	ID       string `json:"id"`
+	ShopName string `json:"shop_name"`
+	Address  string `json:"address"`
+	Contact  string `json:"contact"`
+}
+
 type CreateRetailAccountRequest struct {
 	ShopName string `json:"shop_name" binding:"required"`
 	Address  string `json:"address" binding:"required"`
@@ -42,11 +49,20 @@ type CreateRetailAccountResponse struct {
 type ShopLoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// Second factor, required once the user has 2FA enabled: either a code
+	// from the authenticator app or one of the recovery codes
+	OTPCode      string `json:"otp_code"`
+	RecoveryCode string `json:"recovery_code"`
 }
 
 type ShopLoginResponse struct {
-	Token string `json:"token"`
-	Shop  Shop   `json:"shop"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Shop      Shop      `json:"shop"`
+	User      User      `json:"user"`
+	// TwoFactorEnrollmentRequired means Token can only be used to enroll in
+	// 2FA (see /api/auth/2fa); the user must enroll before anything else
+	TwoFactorEnrollmentRequired bool `json:"two_factor_enrollment_required,omitempty"`
 }
 
 // TODO: Add middleware authentication for master routes
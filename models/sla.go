@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// ClaimSLA is how long a claim may stay in each status before it is overdue.
+// Statuses without a target are never overdue.
+type ClaimSLA map[ClaimStatus]time.Duration
+
+// claimSLA is set once at startup from the configuration
+var claimSLA = ClaimSLA{}
+
+// SetClaimSLA sets the targets claims are checked against. Zero or negative
+// targets are left out.
+func SetClaimSLA(targets ClaimSLA) {
+	claimSLA = ClaimSLA{}
+	for status, target := range targets {
+		if target > 0 {
+			claimSLA[status] = target
+		}
+	}
+}
+
+// ClaimSLATargets returns the targets claims are checked against
+func ClaimSLATargets() ClaimSLA {
+	return claimSLA
+}
+
+// CheckSLA sets when the claim is due to leave its current status and
+// whether it is overdue at now
+func (c *Claim) CheckSLA(now time.Time) {
+	c.SLADueAt = nil
+	c.SLABreached = false
+	target, ok := claimSLA[c.Status]
+	if !ok || c.StatusChangedAt.IsZero() {
+		return
+	}
+	due := c.StatusChangedAt.Add(target)
+	c.SLADueAt = &due
+	c.SLABreached = now.After(due)
+}
+
+// SLAStatusReport is how claims fared against the target for one status,
+// counting each time a claim entered it within the report period
+type SLAStatusReport struct {
+	Status      ClaimStatus `json:"status"`
+	TargetHours float64     `json:"target_hours"`
+	Entered     int         `json:"entered"`
+	// Breached stayed, or have stayed so far, longer than the target
+	Breached int `json:"breached"`
+	// BreachRate is Breached as a share of Entered, 0 when none entered
+	BreachRate float64 `json:"breach_rate"`
+	// Open are still in the status, OpenBreached of them overdue
+	Open         int `json:"open"`
+	OpenBreached int `json:"open_breached"`
+	// AvgHoursInStatus is the average time until claims moved on, null when
+	// none have
+	AvgHoursInStatus *float64 `json:"avg_hours_in_status"`
+}
@@ -0,0 +1,151 @@
+package models
+
+import "time"
+
+// Staff roles. Shop roles belong to a retail shop; the others are
+// headquarters roles attached to the master account's shop.
+const (
+	ShopStaffRole      UserRole = "shop_staff"
+	ShopManagerRole    UserRole = "shop_manager"
+	ClaimsReviewerRole UserRole = "claims_reviewer"
+	MasterAdminRole    UserRole = "master_admin"
+	AuditorRole        UserRole = "auditor"
+)
+
+type Permission string
+
+const (
+	PermCreateClaim       Permission = "claims:create"
+	PermReadShopClaims    Permission = "claims:read_shop"
+	PermCloseClaim        Permission = "claims:close"
+	PermReadAllClaims     Permission = "claims:read_all"
+	PermReviewClaims      Permission = "claims:review"
+	PermReadAllWarranties Permission = "warranties:read_all"
+	PermManageWarranties  Permission = "warranties:manage"
+	PermManageShops       Permission = "shops:manage"
+	PermManageShopUsers   Permission = "users:manage_shop"
+	PermManageAllUsers    Permission = "users:manage_all"
+)
+
+var shopPermissions = []Permission{PermCreateClaim, PermReadShopClaims, PermCloseClaim}
+
+var rolePermissions = map[UserRole][]Permission{
+	ShopStaffRole:   shopPermissions,
+	ShopManagerRole: append([]Permission{PermManageShopUsers}, shopPermissions...),
+	ClaimsReviewerRole: {
+		PermReadAllClaims, PermReviewClaims, PermReadAllWarranties,
+	},
+	MasterAdminRole: {
+		PermReadAllClaims, PermReviewClaims, PermReadAllWarranties, PermManageWarranties,
+		PermManageShops, PermManageShopUsers, PermManageAllUsers,
+	},
+	AuditorRole: {
+		PermReadAllClaims, PermReadAllWarranties,
+	},
+}
+
+// Tokens issued before per-user accounts carry the shop-level roles; they keep
+// the access they had
+var legacyRoles = map[UserRole]UserRole{
+	AdminRole:  ShopManagerRole,
+	MasterRole: MasterAdminRole,
+}
+
+// Can reports whether the role grants permission
+func (r UserRole) Can(permission Permission) bool {
+	if mapped, ok := legacyRoles[r]; ok {
+		r = mapped
+	}
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsShopRole reports whether the role belongs to retail shop staff
+func (r UserRole) IsShopRole() bool {
+	return r == ShopStaffRole || r == ShopManagerRole || r == AdminRole
+}
+
+// RolesWithPermission returns the staff roles granted permission
+func RolesWithPermission(permission Permission) []UserRole {
+	var roles []UserRole
+	for role := range rolePermissions {
+		if role.Can(permission) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// IsValid reports whether r is one of the staff roles users can be given
+func (r UserRole) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+type User struct {
+	ID          string    `json:"id"`
+	ShopID      string    `json:"shop_id"`
+	ShopName    string    `json:"shop_name"`
+	Username    string    `json:"username"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Role        UserRole  `json:"role"`
+	IsActive    bool      `json:"is_active"`
+	TOTPEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Credentials and lockout state, never returned to clients
+	PasswordHash        string     `json:"-"`
+	FailedLoginAttempts int        `json:"-"`
+	LockedUntil         *time.Time `json:"-"`
+	TOTPSecret          string     `json:"-"`
+	TOTPLastStep        int64      `json:"-"`
+}
+
+type CreateUserRequest struct {
+	// ShopID is only honoured for users who can manage all users; shop
+	// managers always create users in their own shop
+	ShopID   string   `json:"shop_id"`
+	Username string   `json:"username" binding:"required"`
+	Password string   `json:"password" binding:"required,min=8"`
+	Name     string   `json:"name" binding:"required"`
+	Role     UserRole `json:"role" binding:"required"`
+	// Email is where headquarters staff are told about overdue claims
+	Email string `json:"email" binding:"omitempty,email,max=100"`
+}
+
+type UpdateUserRequest struct {
+	Name     *string   `json:"name"`
+	Role     *UserRole `json:"role"`
+	IsActive *bool     `json:"is_active"`
+	Password *string   `json:"password" binding:"omitempty,min=8"`
+	// An empty email removes it
+	Email *string `json:"email" binding:"omitempty,max=100"`
+}
+
+// RequiresTwoFactor reports whether the role must use 2FA to sign in.
+// Headquarters roles can settle claims for any shop, so 2FA is mandatory for
+// them and optional for shop staff.
+func (r UserRole) RequiresTwoFactor() bool {
+	return !r.IsShopRole()
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorVerifyResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	// Token replaces an enrollment token once 2FA is enabled
+	Token     string     `json:"token,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
@@ -1,25 +1,221 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Warranty struct {
 	ID           string    `json:"id"`
 	Name         string    `json:"name"`
 	PhoneNumber  string    `json:"phone_number"`
 	Email        string    `json:"email"`
-	PurchaseDate time.Time `json:"purchase_date"`
-	ExpiryDate   time.Time `json:"expiry_date"`
+	PurchaseDate Date      `json:"purchase_date"`
+	ExpiryDate   Date      `json:"expiry_date"` // last day of cover
 	CarPlate     string    `json:"car_plate"`
 	Receipt      string    `json:"receipt"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	// Set when headquarters voided the registration
+	VoidedAt   *time.Time `json:"voided_at,omitempty"`
+	VoidReason string     `json:"void_reason,omitempty"`
+	// Duplicate/fraud assessment made at registration, for headquarters only
+	RiskScore    int                  `json:"risk_score"`
+	RiskReasons  []string             `json:"risk_reasons"`
+	ReviewStatus WarrantyReviewStatus `json:"review_status,omitempty"`
+}
+
+// WarrantyMonths is how long a warranty covers from the purchase date
+const WarrantyMonths = 6
+
+// ActiveOn reports whether the warranty covers day: not voided and not past
+// its expiry date. SQL queries use the same rule, expiry_date >= today.
+func (w Warranty) ActiveOn(day Date) bool {
+	return w.VoidedAt == nil && !w.ExpiryDate.Before(day)
+}
+
+// WarrantyReviewStatus tracks a registration flagged as a likely duplicate
+type WarrantyReviewStatus string
+
+const (
+	// ReviewPending registrations wait in the headquarters review queue
+	ReviewPending WarrantyReviewStatus = "pending"
+	// ReviewCleared registrations were checked and found genuine. Fraudulent
+	// ones are voided instead.
+	ReviewCleared WarrantyReviewStatus = "cleared"
+)
+
+// PublicWarranty is a warranty as shown to customers, without the fraud
+// assessment and internal review state
+type PublicWarranty struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	PhoneNumber  string     `json:"phone_number"`
+	Email        string     `json:"email"`
+	PurchaseDate Date       `json:"purchase_date"`
+	ExpiryDate   Date       `json:"expiry_date"`
+	CarPlate     string     `json:"car_plate"`
+	Receipt      string     `json:"receipt"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	VoidedAt     *time.Time `json:"voided_at,omitempty"`
+}
+
+// Public returns what customers may see of the warranty
+func (w Warranty) Public() PublicWarranty {
+	return PublicWarranty{
+		ID:           w.ID,
+		Name:         w.Name,
+		PhoneNumber:  w.PhoneNumber,
+		Email:        w.Email,
+		PurchaseDate: w.PurchaseDate,
+		ExpiryDate:   w.ExpiryDate,
+		CarPlate:     w.CarPlate,
+		Receipt:      w.Receipt,
+		CreatedAt:    w.CreatedAt,
+		UpdatedAt:    w.UpdatedAt,
+		VoidedAt:     w.VoidedAt,
+	}
+}
+
+// RegistrationWindow holds the rules on when a purchase can be registered
+type RegistrationWindow struct {
+	// MaxDaysAfterPurchase is how late a purchase can be registered; 0 means
+	// any time
+	MaxDaysAfterPurchase int
+}
+
+// Check returns what is wrong with registering a purchase made on
+// purchaseDate, or nil if it can be registered today
+func (w RegistrationWindow) Check(purchaseDate, today Date) *FieldError {
+	if purchaseDate.IsZero() {
+		return &FieldError{Field: "purchase_date", Message: "is required"}
+	}
+	if purchaseDate.After(today) {
+		return &FieldError{Field: "purchase_date", Message: "cannot be in the future"}
+	}
+	if w.MaxDaysAfterPurchase > 0 && today.DaysSince(purchaseDate) > w.MaxDaysAfterPurchase {
+		return &FieldError{
+			Field:   "purchase_date",
+			Message: fmt.Sprintf("must be registered within %d days of purchase", w.MaxDaysAfterPurchase),
+		}
+	}
+	return nil
 }
 
 type CreateWarrantyRequest struct {
-	Name         string    `json:"name" binding:"required"`
-	PhoneNumber  string    `json:"phone_number" binding:"required"`
-	Email        string    `json:"email"`
-	PurchaseDate time.Time `json:"purchase_date" binding:"required"`
-	CarPlate     string    `json:"car_plate" binding:"required"`
-	Receipt      string    `json:"receipt" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	PhoneNumber  string `json:"phone_number" binding:"required"`
+	Email        string `json:"email" binding:"omitempty,email"`
+	PurchaseDate Date   `json:"purchase_date"` // checked by RegistrationWindow
+	CarPlate     string `json:"car_plate" binding:"required"`
+	Receipt      string `json:"receipt" binding:"required"`
+	// NotifyChannel is how the customer wants to hear from us; defaults to
+	// email when given, SMS otherwise
+	NotifyChannel NotificationChannel `json:"notify_channel" binding:"omitempty,oneof=email sms whatsapp"`
+}
+
+// WarrantySearch filters the warranties listed to headquarters. Text fields
+// match partially and ignore case; dates bound the purchase date, both days
+// included. The dates are parsed by the handler since form binding cannot
+// fill a Date.
+type WarrantySearch struct {
+	Name         string `form:"name"`
+	PhoneNumber  string `form:"phone_number"`
+	CarPlate     string `form:"car_plate"`
+	PurchaseFrom *Date  `form:"-"`
+	PurchaseTo   *Date  `form:"-"`
+	// Status is one of active, expired, voided, flagged (awaiting duplicate
+	// review); empty means all
+	Status   string `form:"status" binding:"omitempty,oneof=active expired voided flagged"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type WarrantySearchResult struct {
+	Warranties []Warranty `json:"warranties"`
+	Total      int        `json:"total"`
+	Page       int        `json:"page"`
+	PageSize   int        `json:"page_size"`
+}
+
+// UpdateWarrantyRequest corrects registration details; omitted fields are
+// left as they are
+type UpdateWarrantyRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1"`
+	PhoneNumber *string `json:"phone_number" binding:"omitempty,min=1"`
+	Email       *string `json:"email" binding:"omitempty,email"`
+	CarPlate    *string `json:"car_plate" binding:"omitempty,min=1"`
+	Reason      string  `json:"reason"`
+}
+
+type VoidWarrantyRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type TransferWarrantyRequest struct {
+	CarPlate string `json:"car_plate" binding:"required"`
+	Reason   string `json:"reason"`
+}
+
+type ClearWarrantyReviewRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type WarrantyAuditAction string
+
+const (
+	WarrantyUpdated     WarrantyAuditAction = "update"
+	WarrantyVoided      WarrantyAuditAction = "void"
+	WarrantyTransferred WarrantyAuditAction = "transfer"
+	// WarrantyCleared is a flagged registration found genuine on review
+	WarrantyCleared WarrantyAuditAction = "clear"
+)
+
+// FieldChange is the old and new value of a field changed by an audited action
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type WarrantyAuditEntry struct {
+	ID         string                 `json:"id"`
+	WarrantyID string                 `json:"warranty_id"`
+	Action     WarrantyAuditAction    `json:"action"`
+	ChangedBy  string                 `json:"changed_by"`
+	Changes    map[string]FieldChange `json:"changes"`
+	Reason     string                 `json:"reason"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// WarrantyRiskSignals are what other registrations have in common with a new
+// one. Plates and phone numbers are compared ignoring case, spaces and
+// punctuation; voided registrations are not counted.
+type WarrantyRiskSignals struct {
+	// Other registrations with the same receipt link or receipt file content
+	SameReceipt int
+	// Other registrations of the same plate with the same purchase date
+	SamePlateAndDate int
+	// Distinct plates registered with this phone number, including this one
+	PlatesForPhone int
+}
+
+// Score turns the signals into a risk score from 0 to 100 and the reasons
+// behind it
+func (s WarrantyRiskSignals) Score() (int, []string) {
+	score := 0
+	reasons := []string{}
+	if s.SameReceipt > 0 {
+		score += 70
+		reasons = append(reasons, fmt.Sprintf("receipt already used for %d other registration(s)", s.SameReceipt))
+	}
+	if s.SamePlateAndDate > 0 {
+		score += 50
+		reasons = append(reasons, fmt.Sprintf("%d other registration(s) for this plate with the same purchase date", s.SamePlateAndDate))
+	}
+	if s.PlatesForPhone >= 3 {
+		score += min(15*(s.PlatesForPhone-2), 45)
+		reasons = append(reasons, fmt.Sprintf("phone number registered for %d car plates", s.PlatesForPhone))
+	}
+	return min(score, 100), reasons
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WarrantyImport is one run of the bulk warranty import. A dry run checks
+// the file and counts what would be imported without saving any warranty.
+type WarrantyImport struct {
+	ID         string `json:"id"`
+	Filename   string `json:"filename"`
+	ImportedBy string `json:"imported_by"`
+	DryRun     bool   `json:"dry_run"`
+	TotalRows  int    `json:"total_rows"`
+	// ImportedRows were saved (or would be, in a dry run)
+	ImportedRows int `json:"imported_rows"`
+	// DuplicateRows were imported or registered before, or appear earlier in
+	// the file
+	DuplicateRows int `json:"duplicate_rows"`
+	// ErrorRows failed validation; see the error report
+	ErrorRows      int              `json:"error_rows"`
+	Errors         []ImportRowError `json:"-"`
+	ErrorReportURL string           `json:"error_report_url,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// ImportRowError explains why a row of an import file was not imported. Row
+// is the line number as a spreadsheet shows it, the header being row 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// ImportedWarranty is a valid row of an import file, normalized
+type ImportedWarranty struct {
+	Row          int
+	ImportKey    string
+	Name         string
+	PhoneNumber  string
+	Email        string
+	CarPlate     string
+	Receipt      string
+	PurchaseDate Date
+	ExpiryDate   Date
+}
+
+// NormalizeCarPlate upper-cases a plate and drops spaces and punctuation,
+// e.g. "wxy 1234" becomes "WXY1234"
+func NormalizeCarPlate(plate string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, plate)
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"tayaria-warranty-be/models"
+)
+
+// GatewaySender sends messages through an HTTP gateway that accepts
+//
+//	POST <url>
+//	Authorization: Bearer <token>
+//	{"channel": "sms", "from": "<sender id>", "to": "+60123456789", "message": "..."}
+//
+// and answers 2xx once the message is accepted
+type GatewaySender struct {
+	config GatewayConfig
+	client *http.Client
+}
+
+func NewGatewaySender(config GatewayConfig) *GatewaySender {
+	return &GatewaySender{config: config, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type gatewayRequest struct {
+	Channel models.NotificationChannel `json:"channel"`
+	From    string                     `json:"from,omitempty"`
+	To      string                     `json:"to"`
+	Message string                     `json:"message"`
+}
+
+func (g *GatewaySender) Send(ctx context.Context, channel models.NotificationChannel, to, message string) error {
+	body, err := json.Marshal(gatewayRequest{Channel: channel, From: g.config.SenderID, To: to, Message: message})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create gateway request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.config.Token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach messaging gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("messaging gateway returned %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
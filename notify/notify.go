@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"tayaria-warranty-be/db"
+	"tayaria-warranty-be/metrics"
+	"tayaria-warranty-be/models"
+	"tayaria-warranty-be/utils"
+)
+
+// NormalizePhone puts a customer's phone number in E.164 form
+func NormalizePhone(phone string) (string, error) {
+	return utils.NormalizePhone(phone, countryCode)
+}
+
+// SetPreference records the channel a customer chose
+func SetPreference(ctx context.Context, phone string, channel models.NotificationChannel) error {
+	e164, err := NormalizePhone(phone)
+	if err != nil {
+		return err
+	}
+	return db.SetNotificationPreference(ctx, e164, channel)
+}
+
+// ChannelFor returns how to reach a customer: the channel they chose, or
+// email when they have an address and SMS otherwise. Email is only used when
+// there is an address to send to, and phone channels only with a valid phone.
+func ChannelFor(ctx context.Context, phone, email string) models.NotificationChannel {
+	e164, phoneErr := NormalizePhone(phone)
+
+	var preferred models.NotificationChannel
+	if phoneErr == nil {
+		var err error
+		if preferred, err = db.GetNotificationPreference(ctx, e164); err != nil {
+			slog.WarnContext(ctx, "failed to get notification preference", "error", err)
+		}
+	}
+
+	switch {
+	case preferred.IsMessaging() && phoneErr == nil:
+		return preferred
+	case email != "":
+		return models.EmailChannel
+	case phoneErr == nil:
+		return models.SMSChannel
+	}
+	return ""
+}
+
+// send delivers a message over channel, by email through sendEmail or as a
+// text message to phone. kind names the notification in logs and metrics.
+func send(ctx context.Context, kind string, channel models.NotificationChannel, phone, message string, sendEmail func() error) error {
+	switch {
+	case channel == models.EmailChannel:
+		if err := sendEmail(); err != nil {
+			metrics.EmailSendFailures.WithLabelValues(kind).Inc()
+			return err
+		}
+		return nil
+	case channel.IsMessaging():
+		to, err := NormalizePhone(phone)
+		if err != nil {
+			return err
+		}
+		if sender == nil {
+			return fmt.Errorf("messaging is not initialized")
+		}
+		if err := sender.Send(ctx, channel, to, message); err != nil {
+			metrics.MessageSendFailures.WithLabelValues(string(channel), kind).Inc()
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("no way to reach the customer")
+}
+
+// WarrantyConfirmation tells the customer their warranty is registered
+func WarrantyConfirmation(ctx context.Context, warranty models.Warranty) (models.NotificationChannel, error) {
+	channel := ChannelFor(ctx, warranty.PhoneNumber, warranty.Email)
+	message := fmt.Sprintf("Tayaria: your tyre warranty for %s is registered and valid until %s. File claims at any Tayaria shop.",
+		warranty.CarPlate, warranty.ExpiryDate.Format("2 Jan 2006"))
+	return channel, send(ctx, "warranty_confirmation", channel, warranty.PhoneNumber, message, func() error {
+		return utils.SendWarrantyConfirmationEmail(warranty)
+	})
+}
+
+// WarrantyReminder tells the customer their warranty expires in daysLeft
+// days, over channel (see ChannelFor)
+func WarrantyReminder(ctx context.Context, channel models.NotificationChannel, warranty models.Warranty, daysLeft int) error {
+	message := fmt.Sprintf("Tayaria: your tyre warranty for %s expires on %s (%d days). Visit a Tayaria shop before then to make a claim.",
+		warranty.CarPlate, warranty.ExpiryDate.Format("2 Jan 2006"), daysLeft)
+	return send(ctx, "warranty_reminder", channel, warranty.PhoneNumber, message, func() error {
+		return utils.SendWarrantyReminderEmail(warranty, daysLeft)
+	})
+}
+
+// ClaimUpdate tells the customer their claim changed status
+func ClaimUpdate(ctx context.Context, claim models.Claim) (models.NotificationChannel, error) {
+	channel := ChannelFor(ctx, claim.PhoneNumber, claim.Email)
+	message := fmt.Sprintf("Tayaria: your claim %s for %s is now: %s.",
+		claim.TrackingRef, claim.CarPlate, claim.Status.CustomerLabel())
+	return channel, send(ctx, "claim_update", channel, claim.PhoneNumber, message, func() error {
+		return utils.SendClaimUpdateEmail(claim)
+	})
+}
@@ -0,0 +1,88 @@
+// Package notify sends customer notifications by email, SMS or WhatsApp,
+// following each customer's channel preference.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"tayaria-warranty-be/models"
+)
+
+// Sender delivers a text message to a phone number in E.164 form over SMS
+// or WhatsApp
+type Sender interface {
+	Send(ctx context.Context, channel models.NotificationChannel, to, message string) error
+}
+
+// GatewayConfig configures the HTTP messaging gateway
+type GatewayConfig struct {
+	URL      string
+	Token    string
+	SenderID string
+}
+
+var (
+	sender      Sender
+	countryCode = "60"
+)
+
+// Init selects the messaging backend: "gateway" posts to an HTTP SMS/WhatsApp
+// gateway, "fake" only logs messages (for development). phoneCountryCode is
+// added to local phone numbers.
+func Init(backend string, gateway GatewayConfig, phoneCountryCode string) error {
+	countryCode = phoneCountryCode
+
+	switch backend {
+	case "gateway":
+		if gateway.URL == "" {
+			return fmt.Errorf("messaging gateway URL is not set")
+		}
+		sender = NewGatewaySender(gateway)
+	case "fake":
+		sender = NewFakeSender()
+	default:
+		return fmt.Errorf("unknown messaging backend %q", backend)
+	}
+	return nil
+}
+
+// FakeSender keeps the messages it is asked to send instead of sending them
+type FakeSender struct {
+	mu       sync.Mutex
+	messages []FakeMessage
+}
+
+type FakeMessage struct {
+	Channel models.NotificationChannel
+	To      string
+	Message string
+}
+
+// maxFakeMessages bounds what a long-running development server keeps
+const maxFakeMessages = 100
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+func (f *FakeSender) Send(ctx context.Context, channel models.NotificationChannel, to, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.messages) == maxFakeMessages {
+		f.messages = f.messages[1:]
+	}
+	f.messages = append(f.messages, FakeMessage{Channel: channel, To: to, Message: message})
+	slog.InfoContext(ctx, "fake message sent", "channel", channel, "to", to, "message", message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (f *FakeSender) Messages() []FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeMessage(nil), f.messages...)
+}
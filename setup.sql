@@ -1,4 +1,17 @@
 -- Drop existing tables if they exist (in correct order due to foreign key constraints)
+-- Tables created by db/migrations are dropped too so they are re-applied on startup
+DROP TABLE IF EXISTS schema_migrations CASCADE;
+DROP TABLE IF EXISTS claim_escalations CASCADE;
+DROP TABLE IF EXISTS warranty_imports CASCADE;
+DROP TABLE IF EXISTS notification_preferences CASCADE;
+DROP TABLE IF EXISTS warranty_reminders CASCADE;
+DROP TABLE IF EXISTS warranty_audit_log CASCADE;
+DROP TABLE IF EXISTS claim_inspections CASCADE;
+DROP TABLE IF EXISTS claim_photos CASCADE;
+DROP TABLE IF EXISTS claim_status_history CASCADE;
+DROP TABLE IF EXISTS claim_reference_counters CASCADE;
+DROP TABLE IF EXISTS user_recovery_codes CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
 DROP TABLE IF EXISTS tyre_details CASCADE;
 DROP TABLE IF EXISTS claims CASCADE;
 DROP TABLE IF EXISTS warranties CASCADE;
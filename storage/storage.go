@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned by Open for a key that was never stored
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps uploaded files (claim photos, thumbnails) by key. Keys are
+// slash-separated paths such as "claims/<claim id>/<photo id>.jpg". The local
+// backend is enough for a single instance with a persistent disk; an object
+// store (e.g. Supabase Storage or S3) can implement the same interface.
+type Store interface {
+	Put(ctx context.Context, key string, contentType string, r io.Reader) error
+	// Open returns the object's content; the caller closes it
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var store Store
+
+// Init sets up the store named by backend
+func Init(backend, localDir string) error {
+	switch backend {
+	case "local":
+		local, err := NewLocalStore(localDir)
+		if err != nil {
+			return err
+		}
+		store = local
+		return nil
+	default:
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+func Put(ctx context.Context, key string, contentType string, r io.Reader) error {
+	if store == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return store.Put(ctx, key, contentType, r)
+}
+
+func Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if store == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	return store.Open(ctx, key)
+}
+
+func Delete(ctx context.Context, key string) error {
+	if store == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return store.Delete(ctx, key)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Background work is started through RunInBackground (one-shot tasks such as
+// confirmation emails) or RunWorker (loops such as schedulers) so that
+// shutdown can wait for it to finish before the DB pool is closed.
+var (
+	// workerCtx is cancelled as soon as shutdown starts, so loops stop
+	workerCtx, cancelWorkers = context.WithCancel(context.Background())
+	// taskCtx is only cancelled if one-shot tasks outlive the drain deadline
+	taskCtx, cancelTasks = context.WithCancel(context.Background())
+	backgroundWG         sync.WaitGroup
+)
+
+// RunInBackground runs a one-shot task in a tracked goroutine. Shutdown lets
+// it finish; the context passed to fn is only cancelled if it is still
+// running when the drain deadline passes.
+func RunInBackground(name string, fn func(ctx context.Context)) {
+	run(name, taskCtx, fn)
+}
+
+// RunWorker runs a long-running loop in a tracked goroutine. The context
+// passed to fn is cancelled when shutdown starts; fn should return when it
+// is done.
+func RunWorker(name string, fn func(ctx context.Context)) {
+	run(name, workerCtx, fn)
+}
+
+func run(name string, ctx context.Context, fn func(ctx context.Context)) {
+	backgroundWG.Add(1)
+	go func() {
+		defer backgroundWG.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("background task panicked", "task", name, "panic", r)
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+// StopBackgroundTasks stops the workers and waits for them and the one-shot
+// tasks to return. If ctx is done first, the remaining tasks are cancelled
+// and ctx's error returned.
+func StopBackgroundTasks(ctx context.Context) error {
+	cancelWorkers()
+
+	done := make(chan struct{})
+	go func() {
+		backgroundWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		cancelTasks()
+		return nil
+	case <-ctx.Done():
+		cancelTasks()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStopBackgroundTasksDrainsTasksAndStopsWorkers(t *testing.T) {
+	release := make(chan struct{})
+	taskErr := make(chan error, 1)
+	RunInBackground("one-shot", func(ctx context.Context) {
+		<-release
+		taskErr <- ctx.Err()
+	})
+
+	workerStopped := make(chan struct{})
+	RunWorker("loop", func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	})
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- StopBackgroundTasks(ctx)
+	}()
+
+	select {
+	case <-workerStopped:
+	case <-time.After(time.Second):
+		t.Fatal("worker was not cancelled when shutdown started")
+	}
+
+	// The one-shot task is still draining with a live context
+	close(release)
+	if err := <-taskErr; err != nil {
+		t.Fatalf("one-shot task context = %v, want not cancelled while draining", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("StopBackgroundTasks = %v, want nil", err)
+	}
+}
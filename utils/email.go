@@ -1,17 +1,45 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"tayaria-warranty-be/models"
 
 	"gopkg.in/gomail.v2"
 )
 
+const (
+	smtpHost   = "mail.kitloongholdings.com"
+	smtpPort   = 587
+	smtpSender = "contact.tayaria@kitloongholdings.com"
+)
+
+// smtpPassword authenticates smtpSender; set once at startup from SMTP_PASSWORD
+var smtpPassword string
+
+// InitMailer sets the SMTP password used to send email
+func InitMailer(password string) {
+	smtpPassword = password
+}
+
+// sendMail delivers m through the SMTP server
+func sendMail(m *gomail.Message) error {
+	if smtpPassword == "" {
+		return errors.New("SMTP password not configured")
+	}
+	return gomail.NewDialer(smtpHost, smtpPort, smtpSender, smtpPassword).DialAndSend(m)
+}
+
 // SendWarrantyConfirmationEmail sends a confirmation email to the user when a warranty is registered
 func SendWarrantyConfirmationEmail(warranty models.Warranty) error {
 	m := gomail.NewMessage()
-	m.SetHeader("From", "contact.tayaria@kitloongholdings.com")
+	m.SetHeader("From", smtpSender)
 	m.SetHeader("To", warranty.Email)
 	m.SetHeader("Subject", "Warranty Registration Confirmation - Tayaria")
 
@@ -50,13 +78,123 @@ The Tayaria Team 🛞
 
 	m.SetBody("text/plain", body)
 
-	// Configure SMTP dialer
-	d := gomail.NewDialer("mail.kitloongholdings.com", 587, "contact.tayaria@kitloongholdings.com", "#Temp0000")
-
-	// Send the email
-	if err := d.DialAndSend(m); err != nil {
+	if err := sendMail(m); err != nil {
 		return fmt.Errorf("failed to send warranty confirmation email: %w", err)
 	}
 
 	return nil
 }
+
+// SendWarrantyReminderEmail reminds the customer that their warranty expires
+// in daysLeft days
+func SendWarrantyReminderEmail(warranty models.Warranty, daysLeft int) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", smtpSender)
+	m.SetHeader("To", warranty.Email)
+	m.SetHeader("Subject", fmt.Sprintf("Your Tayaria warranty for %s expires in %d days", warranty.CarPlate, daysLeft))
+
+	body := fmt.Sprintf(`
+Dear %s,
+
+⏰ Your Tayaria tyre warranty for %s expires on %s, %d days from now.
+
+If your tyres have been damaged, visit your nearest Tayaria shop before the expiry date to file a claim:
+https://tayaria.com/where-to-buy/?search=Kuala+Lumpur%%2CFederal+Territory+of+Kuala+Lumpur%%2CMalaysia
+
+Please bring your car and the digital receipt of your purchase. Claims are only valid for tyres with above 6mm of tread depth left and damage that can be repaired.
+
+If you have any questions, please don't hesitate to contact us at contact.tayaria@kitloongholdings.com
+
+Warm regards,
+The Tayaria Team 🛞
+`, warranty.Name, warranty.CarPlate, warranty.ExpiryDate.Format("January 2, 2006"), daysLeft)
+
+	m.SetBody("text/plain", body)
+
+	if err := sendMail(m); err != nil {
+		return fmt.Errorf("failed to send warranty reminder email: %w", err)
+	}
+
+	return nil
+}
+
+// SendClaimUpdateEmail tells the customer their claim changed status
+func SendClaimUpdateEmail(claim models.Claim) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", smtpSender)
+	m.SetHeader("To", claim.Email)
+	m.SetHeader("Subject", fmt.Sprintf("Claim %s update - Tayaria", claim.TrackingRef))
+
+	body := fmt.Sprintf(`
+Dear %s,
+
+Your warranty claim %s for %s has been updated.
+
+📋 Status: %s
+🏪 Shop: %s
+
+You can follow your claim on our website with its reference and your phone number.
+
+If you have any questions, please contact your shop or us at contact.tayaria@kitloongholdings.com
+
+Warm regards,
+The Tayaria Team 🛞
+`, claim.CustomerName, claim.TrackingRef, claim.CarPlate, claim.Status.CustomerLabel(), claim.ShopName)
+
+	m.SetBody("text/plain", body)
+
+	if err := sendMail(m); err != nil {
+		return fmt.Errorf("failed to send claim update email: %w", err)
+	}
+
+	return nil
+}
+
+// SendClaimEscalationEmail tells headquarters staff which claims have been
+// in their status longer than the SLA allows
+func SendClaimEscalationEmail(to []string, claims []models.Claim) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", smtpSender)
+	m.SetHeader("To", to...)
+	m.SetHeader("Subject", fmt.Sprintf("%d overdue warranty claim(s) - Tayaria", len(claims)))
+
+	var lines strings.Builder
+	for _, claim := range claims {
+		overdue := ""
+		if claim.SLADueAt != nil {
+			overdue = time.Since(*claim.SLADueAt).Round(time.Minute).String()
+		}
+		fmt.Fprintf(&lines, "• %s (%s) at %s: %s since %s, overdue by %s\n",
+			claim.TrackingRef, claim.CarPlate, claim.ShopName, claim.Status,
+			models.BusinessTime(claim.StatusChangedAt).Format("January 2, 2006 15:04"), overdue)
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+The following warranty claims have waited longer than their target time and need attention:
+
+%s
+Please review them in the headquarters dashboard.
+
+The Tayaria Warranty System
+`, lines.String())
+
+	m.SetBody("text/plain", body)
+
+	if err := sendMail(m); err != nil {
+		return fmt.Errorf("failed to send claim escalation email: %w", err)
+	}
+
+	return nil
+}
+
+// CheckMailer verifies that the SMTP server accepts TCP connections
+func CheckMailer(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(smtpHost, strconv.Itoa(smtpPort)))
+	if err != nil {
+		return fmt.Errorf("failed to reach SMTP server: %w", err)
+	}
+	return conn.Close()
+}
@@ -1,50 +1,188 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"os"
+	"fmt"
+	"math/big"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 type Claims struct {
+	UserID   string `json:"user_id"`
 	ShopID   string `json:"shop_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// Scope limits what the token can be used for. Empty means full access.
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a JWT token with optional expiration
-// If expiry is nil, the token will never expire
-func GenerateToken(shopID, username, role string, expiry *time.Duration) (string, error) {
-	// Check if JWT secret is set
-	if len(jwtSecret) == 0 {
-		return "", errors.New("JWT_SECRET environment variable not set")
+// ScopeMFAEnrollment tokens can only be used to enroll in two-factor
+// authentication. They are issued at login to users who must use 2FA but
+// have not set it up yet.
+const ScopeMFAEnrollment = "mfa_enrollment"
+
+// verificationKey is a public key accepted for tokens with a matching kid
+type verificationKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+	jwk    JWK
+}
+
+var (
+	signingKey       crypto.Signer
+	signingKeyID     string
+	signingMethod    jwt.SigningMethod
+	verificationKeys map[string]verificationKey
+
+	// Legacy HS256 tokens are only accepted if issued before legacyCutoff,
+	// and only until legacyAcceptUntil
+	legacySecret      []byte
+	legacyCutoff      time.Time
+	legacyAcceptUntil time.Time
+)
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// InitJWTKeys sets the key used to sign tokens and the keys accepted when
+// verifying them. The signing key's public half is always accepted. Key IDs
+// are RFC 7638 thumbprints, so they are stable across restarts and services.
+func InitJWTKeys(signer crypto.Signer, extraVerificationKeys []crypto.PublicKey) error {
+	if signer == nil {
+		return errors.New("JWT signing key not configured")
 	}
 
+	keys := map[string]verificationKey{}
+	for _, public := range append([]crypto.PublicKey{signer.Public()}, extraVerificationKeys...) {
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return err
+		}
+		keys[key.jwk.Kid] = key
+	}
+
+	signingVerificationKey, err := newVerificationKey(signer.Public())
+	if err != nil {
+		return err
+	}
+
+	signingKey = signer
+	signingKeyID = signingVerificationKey.jwk.Kid
+	signingMethod = signingVerificationKey.method
+	verificationKeys = keys
+	return nil
+}
+
+// SetLegacyTokens accepts HS256 tokens signed with secret, as issued before
+// asymmetric signing, if their iat is before cutoff. Once maxAge has passed
+// since cutoff no legacy token is accepted at all; it returns false if that
+// is already the case, meaning the secret can be removed.
+func SetLegacyTokens(secret []byte, cutoff time.Time, maxAge time.Duration) bool {
+	legacySecret = secret
+	legacyCutoff = cutoff
+	legacyAcceptUntil = cutoff.Add(maxAge)
+	return len(secret) > 0 && time.Now().Before(legacyAcceptUntil)
+}
+
+func newVerificationKey(public crypto.PublicKey) (verificationKey, error) {
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		jwk := JWK{Kty: "OKP", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: base64URL(key)}
+		// RFC 7638 thumbprint members in lexicographic order
+		jwk.Kid = thumbprint(map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X})
+		return verificationKey{method: jwt.SigningMethodEdDSA, public: key, jwk: jwk}, nil
+	case *rsa.PublicKey:
+		jwk := JWK{Kty: "RSA", Use: "sig", Alg: "RS256", N: base64URL(key.N.Bytes()), E: base64URL(big.NewInt(int64(key.E)).Bytes())}
+		jwk.Kid = thumbprint(map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N})
+		return verificationKey{method: jwt.SigningMethodRS256, public: key, jwk: jwk}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported JWT key type %T", public)
+	}
+}
+
+func thumbprint(members map[string]string) string {
+	// encoding/json sorts map keys, which gives the canonical form
+	canonical, _ := json.Marshal(members)
+	sum := sha256.Sum256(canonical)
+	return base64URL(sum[:])
+}
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// JWKS returns the public verification keys as a JWK set
+func JWKS() map[string][]JWK {
+	// Current signing key first, then the rest in a stable order
+	keys := []JWK{}
+	if key, ok := verificationKeys[signingKeyID]; ok {
+		keys = append(keys, key.jwk)
+	}
+	kids := make([]string, 0, len(verificationKeys))
+	for kid := range verificationKeys {
+		if kid != signingKeyID {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		keys = append(keys, verificationKeys[kid].jwk)
+	}
+	return map[string][]JWK{"keys": keys}
+}
+
+// GenerateToken creates a JWT token that expires after expiry
+func GenerateToken(userID, shopID, username, role string, expiry time.Duration) (string, error) {
+	return GenerateScopedToken(userID, shopID, username, role, "", expiry)
+}
+
+// GenerateScopedToken creates a JWT token limited to scope
+func GenerateScopedToken(userID, shopID, username, role, scope string, expiry time.Duration) (string, error) {
+	if signingKey == nil {
+		return "", errors.New("JWT signing key not configured")
+	}
+
+	now := time.Now()
 	claims := &Claims{
+		UserID:   userID,
 		ShopID:   shopID,
 		Username: username,
 		Role:     role,
+		Scope:    scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 		},
 	}
 
-	// Set expiration only if provided
-	if expiry != nil {
-		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(*expiry))
-	}
-
 	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(signingMethod, claims)
+	token.Header["kid"] = signingKeyID
 
 	// Sign and get the complete encoded token as a string
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString(signingKey)
 	if err != nil {
 		return "", err
 	}
@@ -53,20 +191,38 @@ func GenerateToken(shopID, username, role string, expiry *time.Duration) (string
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
-	// Check if JWT secret is set
-	if len(jwtSecret) == 0 {
-		return nil, errors.New("JWT_SECRET environment variable not set")
+	if verificationKeys == nil {
+		return nil, errors.New("JWT verification keys not configured")
 	}
 
 	// Parse token
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Tokens from before key rotation: HS256 without a kid
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			if len(legacySecret) == 0 || !time.Now().Before(legacyAcceptUntil) {
+				return nil, errors.New("legacy HS256 tokens are not accepted")
+			}
+			if claims.IssuedAt == nil || !claims.IssuedAt.Before(legacyCutoff) {
+				return nil, errors.New("legacy HS256 token issued after the cutoff")
+			}
+			return legacySecret, nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verificationKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
 		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtSecret, nil
-	})
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{"EdDSA", "RS256", "HS256"}),
+		jwt.WithExpirationRequired(), // tokens without expiry are no longer accepted
+	)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func legacyToken(t *testing.T, secret []byte, issuedAt time.Time) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: "user-1",
+		Role:   "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateTokenLegacyCutoff(t *testing.T) {
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InitJWTKeys(signer, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetLegacyTokens(nil, time.Time{}, 0) })
+
+	secret := []byte("legacy-secret")
+	cutoff := time.Now().Add(-time.Hour)
+	beforeCutoff := legacyToken(t, secret, cutoff.Add(-time.Minute))
+	afterCutoff := legacyToken(t, secret, cutoff.Add(time.Minute))
+
+	if SetLegacyTokens(nil, cutoff, 24*time.Hour) {
+		t.Error("legacy tokens enabled without a secret")
+	}
+	if _, err := ValidateToken(beforeCutoff); err == nil {
+		t.Error("legacy token accepted without a secret")
+	}
+
+	if !SetLegacyTokens(secret, cutoff, 24*time.Hour) {
+		t.Fatal("legacy tokens not enabled within the acceptance window")
+	}
+	if _, err := ValidateToken(beforeCutoff); err != nil {
+		t.Errorf("token issued before the cutoff: %v", err)
+	}
+	if _, err := ValidateToken(afterCutoff); err == nil {
+		t.Error("token issued after the cutoff was accepted")
+	}
+	if _, err := ValidateToken(legacyToken(t, []byte("other-secret"), cutoff.Add(-time.Minute))); err == nil {
+		t.Error("token signed with another secret was accepted")
+	}
+
+	// Once every pre-cutoff token would have expired the legacy path is off
+	if SetLegacyTokens(secret, cutoff, 30*time.Minute) {
+		t.Error("legacy tokens still enabled after the acceptance window")
+	}
+	if _, err := ValidateToken(beforeCutoff); err == nil {
+		t.Error("legacy token accepted after the acceptance window")
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// InitLogger installs a JSON slog logger as the default logger. Records logged
+// with a context get its request ID attached, and secrets and personal data
+// are redacted before anything is written. The standard library log package
+// is routed through the same handler.
+func InitLogger(level string) {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       parseLogLevel(level),
+		ReplaceAttr: redactAttr,
+	})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler adds the request ID from the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+const redacted = "[REDACTED]"
+
+// Attribute keys whose values are never logged
+var secretKeys = map[string]bool{
+	"password":       true,
+	"token":          true,
+	"secret":         true,
+	"authorization":  true,
+	"supabase_key":   true,
+	"database_url":   true,
+	"jwt_secret":     true,
+	"totp_secret":    true,
+	"otp_code":       true,
+	"code":           true, // TOTP code in 2FA requests
+	"recovery_code":  true,
+	"recovery_codes": true,
+	"access_token":   true,
+}
+
+// Attribute keys holding personal data, logged in masked form
+var personalDataKeys = map[string]func(string) string{
+	"name":          MaskName,
+	"customer_name": MaskName,
+	"phone":         MaskPhone,
+	"phone_number":  MaskPhone,
+	"email":         MaskEmail,
+	"car_plate":     MaskCarPlate,
+}
+
+var (
+	credentialsInURL = regexp.MustCompile(`(\w+://[^:/@\s]+):[^@\s]+@`)
+	bearerToken      = regexp.MustCompile(`(?i)bearer\s+[\w\-.]+`)
+	emailAddress     = regexp.MustCompile(`[\w.+\-]+@[\w\-]+\.[\w.\-]+`)
+	phoneNumber      = regexp.MustCompile(`\+\d{1,3}[\d\- ]{7,14}\d|\b0\d{1,2}[\- ]?\d{3,4}[\- ]?\d{4}\b`)
+)
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if secretKeys[key] {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, ScrubString(err.Error()))
+		}
+	}
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+	if mask, ok := personalDataKeys[key]; ok {
+		return slog.String(a.Key, mask(a.Value.String()))
+	}
+	return slog.String(a.Key, ScrubString(a.Value.String()))
+}
+
+// ScrubString removes credentials, bearer tokens, email addresses and phone
+// numbers from free-form text such as error messages
+func ScrubString(s string) string {
+	s = credentialsInURL.ReplaceAllString(s, "$1:"+redacted+"@")
+	s = bearerToken.ReplaceAllString(s, "Bearer "+redacted)
+	s = emailAddress.ReplaceAllStringFunc(s, MaskEmail)
+	s = phoneNumber.ReplaceAllStringFunc(s, MaskPhone)
+	return s
+}
+
+// MaskPhone keeps only the last 4 digits, e.g. +60123456789 -> ********6789
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// MaskEmail keeps the first character and the domain, e.g. j***@email.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return redacted
+	}
+	return string([]rune(email)[:1]) + "***" + email[at:]
+}
+
+// MaskName keeps only initials, e.g. John Doe -> J. D.
+func MaskName(name string) string {
+	parts := strings.Fields(name)
+	for i, part := range parts {
+		parts[i] = string([]rune(part)[:1]) + "."
+	}
+	return strings.Join(parts, " ")
+}
+
+// MaskCarPlate keeps the last 3 characters, e.g. ABC1234 -> ****234
+func MaskCarPlate(plate string) string {
+	if len(plate) <= 3 {
+		return plate
+	}
+	return strings.Repeat("*", len(plate)-3) + plate[len(plate)-3:]
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidPhone is returned for phone numbers that cannot be put in E.164
+// form
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone returns phone in E.164 form, e.g. "+60123456789". Local
+// numbers with a trunk 0 ("012-345 6789") get countryCode (digits only, e.g.
+// "60"); spaces, dashes, dots and brackets are ignored.
+func NormalizePhone(phone, countryCode string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+")
+
+	var digits strings.Builder
+	for _, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || (r == '+' && digits.Len() == 0):
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case international:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = countryCode + number[1:]
+	case !strings.HasPrefix(number, countryCode):
+		// No country code and no trunk prefix, e.g. "123456789"
+		number = countryCode + number
+	}
+
+	// E.164 allows at most 15 digits; anything under 8 is not a phone
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + number, nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name  string
+		phone string
+		want  string
+	}{
+		{"international with +", "+60 12-345 6789", "+60123456789"},
+		{"surrounding spaces", " +60123456789 ", "+60123456789"},
+		{"international with 00", "0060123456789", "+60123456789"},
+		{"trunk 0", "012-345 6789", "+60123456789"},
+		{"landline with brackets and dots", "(03) 1234.5678", "+60312345678"},
+		{"existing country code", "60123456789", "+60123456789"},
+		{"no country code or trunk 0", "123456789", "+60123456789"},
+		{"other country", "+44 20 7946 0958", "+442079460958"},
+		{"15 digits", "+123456789012345", "+123456789012345"},
+		{"8 digits", "+12345678", "+12345678"},
+
+		{"empty", "", ""},
+		{"16 digits", "+1234567890123456", ""},
+		{"7 digits", "+1234567", ""},
+		{"short local number", "12345", ""},
+		{"letters", "012-ABC 6789", ""},
+		{"+ after digits", "60+123456789", ""},
+		{"country code starting with 0", "+0123456789", ""},
+		{"00 then 0", "000123456789", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.phone, "60")
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidPhone) {
+					t.Errorf("NormalizePhone(%q) = %q, %v; want ErrInvalidPhone", tt.phone, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizePhone(%q) = %q, %v; want %q", tt.phone, got, err, tt.want)
+			}
+		})
+	}
+}
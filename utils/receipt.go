@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxReceiptBytes bounds how much of a receipt file is downloaded for hashing
+const maxReceiptBytes = 20 << 20
+
+var receiptClient = &http.Client{Timeout: 30 * time.Second}
+
+// HashReceipt downloads a receipt and returns the hex SHA-256 of its content,
+// so the same file uploaded under a new link is still recognised. Only
+// receipts hosted on allowedHost are fetched; customers choose the link, and
+// the server must not be made to request arbitrary addresses.
+func HashReceipt(ctx context.Context, receiptURL, allowedHost string) (string, error) {
+	u, err := url.Parse(receiptURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid receipt URL: %v", err)
+	}
+	if u.Scheme != "https" || allowedHost == "" || u.Host != allowedHost {
+		return "", fmt.Errorf("receipt is not hosted on %s", allowedHost)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := receiptClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download receipt: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download receipt: status %d", resp.StatusCode)
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.LimitReader(resp.Body, maxReceiptBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read receipt: %v", err)
+	}
+	if n > maxReceiptBytes {
+		return "", fmt.Errorf("receipt is larger than %d bytes", maxReceiptBytes)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B SHA-1 vectors, truncated to our 6 digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+// rfc6238Secret is the ASCII key "12345678901234567890" in base32
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		now := time.Unix(v.unix, 0)
+		step, ok := ValidateTOTP(rfc6238Secret, v.code, now)
+		if !ok {
+			t.Errorf("T=%d: code %s rejected", v.unix, v.code)
+			continue
+		}
+		if want := v.unix / 30; step != want {
+			t.Errorf("T=%d: step = %d, want %d", v.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkewAndFormat(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / 30
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+
+	for offset := int64(-1); offset <= 1; offset++ {
+		step, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+offset), now)
+		if !ok || step != current+offset {
+			t.Errorf("code for step offset %d: step=%d ok=%v", offset, step, ok)
+		}
+	}
+	for _, offset := range []int64{-2, 2} {
+		if _, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+offset), now); ok {
+			t.Errorf("code for step offset %d accepted", offset)
+		}
+	}
+
+	code := totpCode(key, current)
+	if _, ok := ValidateTOTP(strings.ToLower(rfc6238Secret), code[:3]+" "+code[3:], now); !ok {
+		t.Error("code with a space or lowercase secret rejected")
+	}
+	for _, bad := range []string{"", "12345", "1234567", code + "0"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, bad, now); ok {
+			t.Errorf("malformed code %q accepted", bad)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", code, now); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	if got := NormalizeRecoveryCode(" ABCDE-12345 "); got != "abcde12345" {
+		t.Errorf("NormalizeRecoveryCode = %q, want abcde12345", got)
+	}
+}